@@ -0,0 +1,71 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func getCondition(conditions []Condition, condType string) *Condition {
+	for i := range conditions {
+		if conditions[i].Type == condType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+func setCondition(conditions *[]Condition, generation int64, condType string, status corev1.ConditionStatus, reason, message string) {
+	cond := getCondition(*conditions, condType)
+	if cond == nil {
+		*conditions = append(*conditions, Condition{Type: condType})
+		cond = &(*conditions)[len(*conditions)-1]
+	}
+	if cond.Status != status {
+		cond.Status = status
+		cond.LastTransitionTime = metav1.Now()
+	}
+	cond.ObservedGeneration = generation
+	cond.Reason = reason
+	cond.Message = message
+}
+
+// GetCondition returns the condition of the given type or nil if it is not set
+func (s *KeystoneServerStatus) GetCondition(condType string) *Condition {
+	return getCondition(s.Conditions, condType)
+}
+
+// SetCondition adds or updates the condition, transition time is only changed when status changes
+func (s *KeystoneServerStatus) SetCondition(condType string, status corev1.ConditionStatus, reason, message string) {
+	setCondition(&s.Conditions, s.ObservedGeneration, condType, status, reason, message)
+}
+
+// IsConditionTrue reports whether the condition of the given type is set and true
+func (s *KeystoneServerStatus) IsConditionTrue(condType string) bool {
+	cond := s.GetCondition(condType)
+	return cond != nil && cond.Status == corev1.ConditionTrue
+}
+
+// GetCondition returns the condition of the given type or nil if it is not set
+func (s *IdentityStatus) GetCondition(condType string) *Condition {
+	return getCondition(s.Conditions, condType)
+}
+
+// SetCondition adds or updates the condition, transition time is only changed when status changes
+func (s *IdentityStatus) SetCondition(condType string, status corev1.ConditionStatus, reason, message string) {
+	setCondition(&s.Conditions, s.ObservedGeneration, condType, status, reason, message)
+}
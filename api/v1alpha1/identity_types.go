@@ -0,0 +1,29 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// IdentityFinalizer keeps identity resources until they are removed from keystone
+const IdentityFinalizer = "openstack.osop.org/identity"
+
+// IdentityStatus defines the observed state shared by keystone identity resources
+type IdentityStatus struct {
+	// ObservedGeneration is the object generation the status was computed for
+	ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+	Conditions         []Condition `json:"conditions,omitempty"`
+	// Created reports whether the operator created the keystone counterpart, only created
+	// counterparts are removed from keystone when the object is deleted
+	Created bool `json:"created,omitempty"`
+}
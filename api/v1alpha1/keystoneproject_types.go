@@ -0,0 +1,70 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KeystoneProjectSpec defines the desired state of KeystoneProject
+type KeystoneProjectSpec struct {
+	// ServerRef references the KeystoneServer managing the project, its bootstrap admin is used
+	ServerRef corev1.LocalObjectReference `json:"serverRef"`
+	// Name of the project in keystone, the object name is used if empty
+	Name string `json:"name,omitempty"`
+	// Adopt takes over an existing keystone project with the same name, adopted projects are
+	// updated to match the spec but never removed from keystone
+	Adopt bool `json:"adopt,omitempty"`
+	// Domain is the name of the project domain, "Default" if empty
+	Domain      string `json:"domain,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// KeystoneProjectStatus defines the observed state of KeystoneProject
+type KeystoneProjectStatus struct {
+	IdentityStatus `json:",inline"`
+	// ID of the project in keystone
+	ID string `json:"id,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// KeystoneProject is the Schema for the keystoneprojects API. An existing keystone project with
+// the same name is only taken over if spec.adopt is set.
+type KeystoneProject struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KeystoneProjectSpec   `json:"spec,omitempty"`
+	Status KeystoneProjectStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KeystoneProjectList contains a list of KeystoneProject
+type KeystoneProjectList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KeystoneProject `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KeystoneProject{}, &KeystoneProjectList{})
+}
@@ -0,0 +1,68 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KeystoneRoleSpec defines the desired state of KeystoneRole
+type KeystoneRoleSpec struct {
+	// ServerRef references the KeystoneServer managing the role, its bootstrap admin is used
+	ServerRef corev1.LocalObjectReference `json:"serverRef"`
+	// Name of the role in keystone, the object name is used if empty
+	Name string `json:"name,omitempty"`
+	// Adopt takes over an existing keystone role with the same name, adopted roles are
+	// updated to match the spec but never removed from keystone
+	Adopt bool `json:"adopt,omitempty"`
+	// Domain is the name of the domain of a domain-specific role, the role is global if empty
+	Domain      string `json:"domain,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// KeystoneRoleStatus defines the observed state of KeystoneRole
+type KeystoneRoleStatus struct {
+	IdentityStatus `json:",inline"`
+	// ID of the role in keystone
+	ID string `json:"id,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// KeystoneRole is the Schema for the keystoneroles API. An existing keystone role with
+// the same name is only taken over if spec.adopt is set.
+type KeystoneRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KeystoneRoleSpec   `json:"spec,omitempty"`
+	Status KeystoneRoleStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KeystoneRoleList contains a list of KeystoneRole
+type KeystoneRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KeystoneRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KeystoneRole{}, &KeystoneRoleList{})
+}
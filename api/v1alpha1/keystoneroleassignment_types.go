@@ -0,0 +1,74 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KeystoneRoleAssignmentSpec defines the desired state of KeystoneRoleAssignment
+type KeystoneRoleAssignmentSpec struct {
+	// ServerRef references the KeystoneServer managing the assignment, its bootstrap admin is used
+	ServerRef corev1.LocalObjectReference `json:"serverRef"`
+	// Role is the name of the granted role
+	Role string `json:"role"`
+	// RoleDomain is the name of the domain of a domain-specific role, the role is global if empty
+	RoleDomain string `json:"roleDomain,omitempty"`
+	// User is the name of the user the role is granted to
+	User string `json:"user"`
+	// UserDomain is the name of the user domain, "Default" if empty
+	UserDomain string `json:"userDomain,omitempty"`
+	// Project is the name of the project the role is granted on
+	Project string `json:"project"`
+	// ProjectDomain is the name of the project domain, "Default" if empty
+	ProjectDomain string `json:"projectDomain,omitempty"`
+}
+
+// KeystoneRoleAssignmentStatus defines the observed state of KeystoneRoleAssignment
+type KeystoneRoleAssignmentStatus struct {
+	IdentityStatus `json:",inline"`
+	// RoleID, UserID and ProjectID identify the granted assignment in keystone
+	RoleID    string `json:"roleID,omitempty"`
+	UserID    string `json:"userID,omitempty"`
+	ProjectID string `json:"projectID,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// KeystoneRoleAssignment is the Schema for the keystoneroleassignments API. Assignments
+// granted before the object took them over are kept in keystone when it is deleted.
+type KeystoneRoleAssignment struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KeystoneRoleAssignmentSpec   `json:"spec,omitempty"`
+	Status KeystoneRoleAssignmentStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KeystoneRoleAssignmentList contains a list of KeystoneRoleAssignment
+type KeystoneRoleAssignmentList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KeystoneRoleAssignment `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KeystoneRoleAssignment{}, &KeystoneRoleAssignmentList{})
+}
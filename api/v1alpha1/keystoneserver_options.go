@@ -0,0 +1,166 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"sort"
+
+	osconf "github.com/dukov/osop-common/pkg/openstack/config"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// AllowUnknownConfigAnnotation set to "true" on a KeystoneServer accepts config sections and
+// options the release tables do not know about, for options missing from the tables or added
+// by keystone plugins. They are passed to keystone unchanged.
+const AllowUnknownConfigAnnotation = "openstack.osop.org/allow-unknown-config"
+
+// steinConfigOptions lists keystone.conf sections and options known to stein. Sections with
+// a nil option list are shared with oslo libraries, their options are not checked.
+var steinConfigOptions = map[string][]string{
+	"DEFAULT":                      nil,
+	"application_credential":       {"driver", "caching", "cache_time", "user_limit"},
+	"assignment":                   {"driver", "prohibited_implied_role"},
+	"auth":                         {"methods", "password", "token", "external", "oauth1", "mapped", "application_credential"},
+	"cache":                        nil,
+	"catalog":                      {"template_file", "driver", "caching", "cache_time", "list_limit"},
+	"cors":                         nil,
+	"credential":                   {"driver", "provider", "key_repository", "caching", "cache_time", "auth_ttl"},
+	"database":                     nil,
+	"domain_config":                {"driver", "caching", "cache_time", "additional_whitelisted_options", "additional_sensitive_options"},
+	"endpoint_filter":              {"driver", "return_all_endpoints_if_no_filter"},
+	"endpoint_policy":              {"driver"},
+	"federation":                   {"driver", "assertion_prefix", "remote_id_attribute", "federated_domain_name", "trusted_dashboard", "sso_callback_template", "caching"},
+	"fernet_receipts":              {"key_repository", "max_active_keys"},
+	"fernet_tokens":                {"key_repository", "max_active_keys"},
+	"healthcheck":                  nil,
+	"identity":                     {"default_domain_id", "domain_specific_drivers_enabled", "domain_configurations_from_database", "domain_config_dir", "driver", "caching", "cache_time", "max_password_length", "list_limit", "password_hash_algorithm", "password_hash_rounds", "salt_bytesize"},
+	"identity_mapping":             {"driver", "generator", "backward_compatible_ids"},
+	"ldap":                         nil,
+	"memcache":                     nil,
+	"oauth1":                       {"driver", "request_token_duration", "access_token_duration"},
+	"oslo_messaging_amqp":          nil,
+	"oslo_messaging_kafka":         nil,
+	"oslo_messaging_notifications": nil,
+	"oslo_messaging_rabbit":        nil,
+	"oslo_middleware":              nil,
+	"oslo_policy":                  nil,
+	"policy":                       {"driver", "list_limit"},
+	"profiler":                     nil,
+	"receipt":                      {"expiration", "provider", "caching", "cache_time", "cache_on_issue"},
+	"resource":                     {"driver", "caching", "cache_time", "list_limit", "admin_project_domain_name", "admin_project_name", "project_name_url_safe", "domain_name_url_safe"},
+	"revoke":                       {"driver", "expiration_buffer", "caching", "cache_time"},
+	"role":                         {"driver", "caching", "cache_time", "list_limit"},
+	"saml":                         nil,
+	"security_compliance":          {"disable_user_account_days_inactive", "lockout_failure_attempts", "lockout_duration", "password_expires_days", "unique_last_password_count", "minimum_password_age", "password_regex", "password_regex_description", "change_password_upon_first_use"},
+	"shadow_users":                 {"driver"},
+	"token":                        {"expiration", "provider", "caching", "cache_time", "cache_on_issue", "allow_rescope_scoped_token", "infer_roles", "allow_expired_window", "revoke_by_id"},
+	"tokenless_auth":               {"trusted_issuer", "protocol", "issuer_attribute"},
+	"totp":                         {"included_previous_windows"},
+	"trust":                        {"allow_redelegation", "max_redelegation_count", "driver"},
+	"unified_limit":                {"driver", "caching", "cache_time", "list_limit", "enforcement_model"},
+	"wsgi":                         {"debug_middleware"},
+}
+
+// withSections returns a copy of base extended with sections added by a later release
+func withSections(base map[string][]string, added map[string][]string) map[string][]string {
+	out := make(map[string][]string, len(base)+len(added))
+	for section, options := range base {
+		out[section] = options
+	}
+	for section, options := range added {
+		out[section] = options
+	}
+	return out
+}
+
+var trainConfigOptions = withSections(steinConfigOptions, map[string][]string{
+	"access_rules_config": {"driver", "caching", "cache_time", "rules_file", "permissive"},
+})
+
+var ussuriConfigOptions = withSections(trainConfigOptions, map[string][]string{
+	"jwt_tokens": {"jws_public_key_repository", "jws_private_key_repository"},
+})
+
+// ReleaseConfigOptions holds keystone.conf sections and options keyed by release name
+var ReleaseConfigOptions = map[string]map[string][]string{
+	"stein":  steinConfigOptions,
+	"train":  trainConfigOptions,
+	"ussuri": ussuriConfigOptions,
+}
+
+// UnknownConfigOptions lists sections and options of config the release tables do not know about
+// as "[section]" or "[section] option"
+func UnknownConfigOptions(config osconf.IniFile, release string) []string {
+	unknown := []string{}
+	walkUnknownConfigOptions(config, release, func(section, option string) {
+		if option == "" {
+			unknown = append(unknown, "["+section+"]")
+		} else {
+			unknown = append(unknown, "["+section+"] "+option)
+		}
+	})
+	return unknown
+}
+
+// validateConfigOptions rejects sections and options of config the release tables do not know about
+func validateConfigOptions(config osconf.IniFile, release string, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	walkUnknownConfigOptions(config, release, func(section, option string) {
+		if option == "" {
+			allErrs = append(allErrs, field.Invalid(path.Key(section), section,
+				fmt.Sprintf("section is unknown to release %s, set the %s annotation to allow it", release, AllowUnknownConfigAnnotation)))
+			return
+		}
+		allErrs = append(allErrs, field.Invalid(path.Key(section).Key(option), config[section][option],
+			fmt.Sprintf("option is unknown to release %s, set the %s annotation to allow it", release, AllowUnknownConfigAnnotation)))
+	})
+	return allErrs
+}
+
+// walkUnknownConfigOptions calls unknown in sorted order for every section, with an empty option,
+// and every option of config the release tables do not know about
+func walkUnknownConfigOptions(config osconf.IniFile, release string, unknown func(section, option string)) {
+	known, ok := ReleaseConfigOptions[NormalizeRelease(release)]
+	if !ok {
+		return
+	}
+	sections := make([]string, 0, len(config))
+	for section := range config {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+	for _, section := range sections {
+		knownOptions, ok := known[section]
+		if !ok {
+			unknown(section, "")
+			continue
+		}
+		if knownOptions == nil {
+			continue
+		}
+		options := make([]string, 0, len(config[section]))
+		for option := range config[section] {
+			options = append(options, option)
+		}
+		sort.Strings(options)
+		for _, option := range options {
+			if !containsString(knownOptions, option) {
+				unknown(section, option)
+			}
+		}
+	}
+}
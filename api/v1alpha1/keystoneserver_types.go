@@ -17,24 +17,329 @@ package v1alpha1
 
 import (
 	osconf "github.com/dukov/osop-common/pkg/openstack/config"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// FernetSpec defines fernet key rotation settings
+type FernetSpec struct {
+	// RotationInterval is the period between key rotations, keys are never rotated if unset
+	RotationInterval *metav1.Duration `json:"rotationInterval,omitempty"`
+	// MaxActiveKeys is the number of keys (including the staged one) kept after rotation
+	// +kubebuilder:validation:Minimum=3
+	MaxActiveKeys *int32 `json:"maxActiveKeys,omitempty"`
+}
+
+// CredentialSpec defines credential encryption key settings
+type CredentialSpec struct {
+	// RotationGeneration triggers a credential key rotation followed by
+	// keystone-manage credential_migrate each time it is increased, keys are not
+	// rotated again before the previous migration succeeded
+	RotationGeneration int64 `json:"rotationGeneration,omitempty"`
+}
+
+// Keys expected in the bootstrap admin credentials Secret
+const (
+	AdminUsernameKey = "username"
+	AdminPasswordKey = "password"
+)
+
+// BootstrapSpec defines the admin account and identity endpoints created by keystone-manage bootstrap
+type BootstrapSpec struct {
+	// AdminSecretRef references a Secret holding the admin password under the "password" key
+	// and optionally the admin user name under the "username" key
+	AdminSecretRef corev1.LocalObjectReference `json:"adminSecretRef"`
+	AdminProject   string                      `json:"adminProject,omitempty"`
+	AdminRole      string                      `json:"adminRole,omitempty"`
+	Region         string                      `json:"region,omitempty"`
+	PublicURL      string                      `json:"publicURL,omitempty"`
+	InternalURL    string                      `json:"internalURL,omitempty"`
+	AdminURL       string                      `json:"adminURL,omitempty"`
+}
+
+// ServiceSpec defines the Service exposing the keystone API
+type ServiceSpec struct {
+	Type        corev1.ServiceType `json:"type,omitempty"`
+	Port        int32              `json:"port,omitempty"`
+	NodePort    int32              `json:"nodePort,omitempty"`
+	Annotations map[string]string  `json:"annotations,omitempty"`
+}
+
+// IngressSpec defines the Ingress exposing the public identity endpoint
+type IngressSpec struct {
+	Host string `json:"host"`
+	// TLSSecretName references a Secret with the certificate for the host, TLS is disabled if empty
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+	// Class is set as kubernetes.io/ingress.class annotation
+	Class       string            `json:"class,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// DatabaseSpec defines the database keystone connects to
+type DatabaseSpec struct {
+	Host string `json:"host"`
+	Port int32  `json:"port,omitempty"`
+	Name string `json:"name,omitempty"`
+	User string `json:"user,omitempty"`
+	// PasswordSecretRef selects the key of a Secret holding the database user password
+	PasswordSecretRef corev1.SecretKeySelector `json:"passwordSecretRef"`
+	// AdminSecretRef references a Secret holding the password of a database account allowed
+	// to create databases and users under the "password" key and optionally its name under
+	// the "username" key. The keystone database and user are created before db_sync if set.
+	AdminSecretRef *corev1.LocalObjectReference `json:"adminSecretRef,omitempty"`
+	// ClientImage provides the mysql client used to provision the database
+	ClientImage string `json:"clientImage,omitempty"`
+}
+
+// MessagingSpec defines the RabbitMQ broker keystone sends notifications to
+type MessagingSpec struct {
+	// Hosts are broker addresses in host or host:port form
+	// +kubebuilder:validation:MinItems=1
+	Hosts []string `json:"hosts"`
+	VHost string   `json:"vhost,omitempty"`
+	User  string   `json:"user"`
+	// PasswordSecretRef selects the key of a Secret holding the broker user password
+	PasswordSecretRef corev1.SecretKeySelector `json:"passwordSecretRef"`
+	TLS               *MessagingTLSSpec        `json:"tls,omitempty"`
+}
+
+// MessagingTLSSpec enables TLS connections to the broker
+type MessagingTLSSpec struct {
+	// CASecretName references a Secret holding the CA bundle under the "ca.crt" key,
+	// system CAs are used if empty
+	CASecretName string `json:"caSecretName,omitempty"`
+}
+
+// CacheSpec defines the memcached servers keystone caches to, either listed
+// explicitly or resolved from ready endpoints of a Service
+type CacheSpec struct {
+	// Servers are memcached addresses in host or host:port form, they are mutually exclusive with ServiceRef
+	Servers    []string         `json:"servers,omitempty"`
+	ServiceRef *CacheServiceRef `json:"serviceRef,omitempty"`
+}
+
+// CacheServiceRef references a memcached Service in the KeystoneServer namespace. Endpoints
+// with a hostname, as published for StatefulSet pods behind a headless Service, are rendered
+// as their DNS names and other endpoints as their IPs.
+type CacheServiceRef struct {
+	Name string `json:"name"`
+	// Port is the name of the Service port, the first one is used if empty
+	Port string `json:"port,omitempty"`
+}
+
+// ConfigKey selects a keystone.conf option, or a whole section if Key is empty
+type ConfigKey struct {
+	Section string `json:"section"`
+	Key     string `json:"key,omitempty"`
+}
+
+// KeystoneSettings defines typed keystone.conf options, raw config overrides are applied on top of them
+type KeystoneSettings struct {
+	// MaxTokenSize is [DEFAULT] max_token_size
+	// +kubebuilder:validation:Minimum=1
+	MaxTokenSize   *int32                  `json:"maxTokenSize,omitempty"`
+	Token          *TokenSettings          `json:"token,omitempty"`
+	Lockout        *LockoutSettings        `json:"lockout,omitempty"`
+	PasswordPolicy *PasswordPolicySettings `json:"passwordPolicy,omitempty"`
+}
+
+// TokenSettings defines [token] options
+type TokenSettings struct {
+	// Expiration is the token lifetime, rounded down to seconds
+	Expiration *metav1.Duration `json:"expiration,omitempty"`
+	// Provider is [token] provider, only fernet keys are managed by the operator
+	// +kubebuilder:validation:Enum=fernet
+	Provider string `json:"provider,omitempty"`
+}
+
+// LockoutSettings defines [security_compliance] user lockout options
+type LockoutSettings struct {
+	// FailureAttempts is the number of failed authentications before a user is locked
+	// +kubebuilder:validation:Minimum=1
+	FailureAttempts *int32 `json:"failureAttempts,omitempty"`
+	// Duration a user stays locked, rounded down to seconds
+	Duration *metav1.Duration `json:"duration,omitempty"`
+}
+
+// PasswordPolicySettings defines [security_compliance] password options
+type PasswordPolicySettings struct {
+	// Regex passwords must match
+	Regex string `json:"regex,omitempty"`
+	// RegexDescription is shown to users whose password does not match Regex
+	RegexDescription string `json:"regexDescription,omitempty"`
+	// ExpiresDays is the number of days a password stays valid
+	// +kubebuilder:validation:Minimum=0
+	ExpiresDays *int32 `json:"expiresDays,omitempty"`
+	// UniqueLastPasswordCount is the number of previous passwords which can not be reused
+	// +kubebuilder:validation:Minimum=1
+	UniqueLastPasswordCount *int32 `json:"uniqueLastPasswordCount,omitempty"`
+	// MinimumAgeDays is the number of days a password must be used before it can be changed
+	// +kubebuilder:validation:Minimum=0
+	MinimumAgeDays *int32 `json:"minimumAgeDays,omitempty"`
+	// ChangeUponFirstUse forces users to change their password after it was set by an admin
+	ChangeUponFirstUse *bool `json:"changeUponFirstUse,omitempty"`
+}
+
+// DomainSpec defines a domain-specific identity backend rendered into keystone.<name>.conf
+type DomainSpec struct {
+	// Name of the keystone domain the backend serves
+	Name string `json:"name"`
+	// Config holds [identity] and [ldap] options of the domain, [identity] driver defaults to ldap
+	Config osconf.IniFile `json:"config,omitempty"`
+	// BindSecretRef references a Secret holding the LDAP bind password under the "password" key
+	// and optionally the bind DN under the "username" key
+	BindSecretRef *corev1.LocalObjectReference `json:"bindSecretRef,omitempty"`
+}
+
 // KeystoneServerSpec defines the desired state of KeystoneServer
 type KeystoneServerSpec struct {
-	Image    string         `json:"image,omitempty"`
-	Release  string         `json:"release,omitempty"`
-	Replicas *int32         `json:"replicas,omitempty"`
-	Config   osconf.IniFile `json:"config,omitempty"`
-	Policy   osconf.Policy  `json:"policy,omitempty"`
+	// Image overrides the keystone image, the default image of the release is used if empty
+	Image    string `json:"image,omitempty"`
+	Release  string `json:"release,omitempty"`
+	Replicas *int32 `json:"replicas,omitempty"`
+	// Config is merged on top of release defaults of keystone.conf. Sections and options
+	// unknown to the release are rejected unless the openstack.osop.org/allow-unknown-config
+	// annotation is "true".
+	Config osconf.IniFile `json:"config,omitempty"`
+	Policy osconf.Policy  `json:"policy,omitempty"`
+	// Settings are typed keystone.conf options, config overrides are applied on top of them
+	Settings *KeystoneSettings `json:"settings,omitempty"`
+	// ConfigRemove lists options dropped from the rendered keystone.conf, including release
+	// defaults, they must not be set in config at the same time
+	ConfigRemove []ConfigKey `json:"configRemove,omitempty"`
+	// Resources of the keystone API container
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// PolicyHotReload mounts policy.yaml so keystone picks up changes itself,
+	// policy-only changes then do not trigger a rolling restart
+	PolicyHotReload bool `json:"policyHotReload,omitempty"`
+	// SensitiveConfigInSecret moves options holding credentials out of keystone.conf
+	// into a keystone.conf.d drop-in file stored in a Secret
+	SensitiveConfigInSecret bool           `json:"sensitiveConfigInSecret,omitempty"`
+	Fernet                  FernetSpec     `json:"fernet,omitempty"`
+	Credential              CredentialSpec `json:"credential,omitempty"`
+	Bootstrap               *BootstrapSpec `json:"bootstrap,omitempty"`
+	Service                 ServiceSpec    `json:"service,omitempty"`
+	Ingress                 *IngressSpec   `json:"ingress,omitempty"`
+	// Database is required unless [database] connection is set in config
+	Database *DatabaseSpec `json:"database,omitempty"`
+	// Messaging configures notifications, they are disabled if neither messaging
+	// nor [DEFAULT] transport_url in config is set
+	Messaging *MessagingSpec `json:"messaging,omitempty"`
+	// Cache configures memcached, caching is disabled if neither cache nor
+	// [cache] memcache_servers in config is set
+	Cache *CacheSpec `json:"cache,omitempty"`
+	// Domains are rendered into the domain_config_dir keystone reads domain-specific
+	// identity backends from
+	Domains []DomainSpec `json:"domains,omitempty"`
+}
+
+// Fernet key repository states
+const (
+	FernetKeysInitialized = "Initialized"
+	FernetKeysRotated     = "Rotated"
+)
+
+// FernetKeysStatus defines the observed state of the fernet key repository
+type FernetKeysStatus struct {
+	KeyCount         int32        `json:"keyCount,omitempty"`
+	RotationState    string       `json:"rotationState,omitempty"`
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+}
+
+// Credential key repository migration states
+const (
+	CredentialKeysMigrating       = "Migrating"
+	CredentialKeysMigrated        = "Migrated"
+	CredentialKeysMigrationFailed = "MigrationFailed"
+)
+
+// CredentialKeysStatus defines the observed state of the credential key repository
+type CredentialKeysStatus struct {
+	KeyCount           int32  `json:"keyCount,omitempty"`
+	RotationGeneration int64  `json:"rotationGeneration,omitempty"`
+	MigrationState     string `json:"migrationState,omitempty"`
+}
+
+// DatabaseStatus defines the observed state of the keystone database
+type DatabaseStatus struct {
+	// SyncedRelease is the release the database schema was last synced to
+	SyncedRelease string `json:"syncedRelease,omitempty"`
+	// SyncedImage is the image which ran the last successful db_sync
+	SyncedImage string `json:"syncedImage,omitempty"`
+	// ProvisionJob is the name of the last completed database provisioning Job
+	ProvisionJob string `json:"provisionJob,omitempty"`
+}
+
+// Rolling upgrade phases
+const (
+	UpgradePhaseExpand    = "Expand"
+	UpgradePhaseRollout   = "Rollout"
+	UpgradePhaseMigrate   = "Migrate"
+	UpgradePhaseContract  = "Contract"
+	UpgradePhaseCompleted = "Completed"
+)
+
+// UpgradeStatus defines the observed state of a rolling release upgrade
+type UpgradeStatus struct {
+	FromRelease string `json:"fromRelease,omitempty"`
+	ToRelease   string `json:"toRelease,omitempty"`
+	Phase       string `json:"phase,omitempty"`
+}
+
+// BootstrapStatus defines the observed state of keystone bootstrap
+type BootstrapStatus struct {
+	// Job is the name of the last completed bootstrap Job
+	Job string `json:"job,omitempty"`
+}
+
+// Condition types
+const (
+	ConditionReleaseSupported    = "ReleaseSupported"
+	ConditionUpgradeAllowed      = "UpgradeAllowed"
+	ConditionConfigRendered      = "ConfigRendered"
+	ConditionDatabaseProvisioned = "DatabaseProvisioned"
+	ConditionDatabaseSynced      = "DatabaseSynced"
+	ConditionBootstrapped        = "Bootstrapped"
+	ConditionCacheAvailable      = "CacheAvailable"
+	ConditionDeploymentAvailable = "DeploymentAvailable"
+	ConditionReady               = "Ready"
+)
+
+// Condition describes the state of a KeystoneServer aspect at a certain point
+type Condition struct {
+	Type               string                 `json:"type"`
+	Status             corev1.ConditionStatus `json:"status"`
+	ObservedGeneration int64                  `json:"observedGeneration,omitempty"`
+	LastTransitionTime metav1.Time            `json:"lastTransitionTime,omitempty"`
+	Reason             string                 `json:"reason,omitempty"`
+	Message            string                 `json:"message,omitempty"`
 }
 
 // KeystoneServerStatus defines the observed state of KeystoneServer
 type KeystoneServerStatus struct {
-	Ready bool `json:"ready,omitempty"`
+	// ObservedGeneration is the KeystoneServer generation the status was computed for
+	ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+	Conditions         []Condition `json:"conditions,omitempty"`
+	// AvailableReplicas and UpdatedReplicas are taken from the owned Deployment
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+	UpdatedReplicas   int32 `json:"updatedReplicas,omitempty"`
+	// Image is the keystone image API pods and Jobs run, the release image if spec.image is empty
+	Image string `json:"image,omitempty"`
+
+	FernetKeys     FernetKeysStatus     `json:"fernetKeys,omitempty"`
+	CredentialKeys CredentialKeysStatus `json:"credentialKeys,omitempty"`
+	Database       DatabaseStatus       `json:"database,omitempty"`
+	Bootstrap      BootstrapStatus      `json:"bootstrap,omitempty"`
+	Upgrade        UpgradeStatus        `json:"upgrade,omitempty"`
+	// ServiceDNSName is the cluster DNS name of the keystone API Service
+	ServiceDNSName string `json:"serviceDNSName,omitempty"`
+	// OverriddenOptions lists typed settings replaced by config overrides as "[section] option"
+	OverriddenOptions []string `json:"overriddenOptions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:storageversion
 
 // KeystoneServer is the Schema for the keystoneservers API
 type KeystoneServer struct {
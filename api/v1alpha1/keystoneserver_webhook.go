@@ -0,0 +1,405 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	osconf "github.com/dukov/osop-common/pkg/openstack/config"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// ReplicasDefault is the number of API pods run when spec.replicas is not set
+var ReplicasDefault int32 = 1
+
+// ResourcesDefault is applied to the API container when spec.resources is not set
+var ResourcesDefault = corev1.ResourceRequirements{
+	Requests: corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("100m"),
+		corev1.ResourceMemory: resource.MustParse("256Mi"),
+	},
+	Limits: corev1.ResourceList{
+		corev1.ResourceMemory: resource.MustParse("1Gi"),
+	},
+}
+
+// FernetMaxActiveKeysDefault matches keystone [fernet_tokens] max_active_keys default
+var FernetMaxActiveKeysDefault int32 = 3
+
+// TokenExpirationDefault is the [token] expiration rendered unless settings or config override it
+var TokenExpirationDefault = 12 * time.Hour
+
+// log is for logging in this package.
+var keystoneserverlog = logf.Log.WithName("keystoneserver-resource")
+
+func (r *KeystoneServer) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-openstack-osop-org-v1alpha1-keystoneserver,mutating=true,failurePolicy=fail,groups=openstack.osop.org,resources=keystoneservers,verbs=create;update,versions=v1alpha1,name=mkeystoneserver.kb.io
+
+var _ webhook.Defaulter = &KeystoneServer{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *KeystoneServer) Default() {
+	// the image is not defaulted, an empty image follows the release on upgrades
+	r.Spec.Release = NormalizeRelease(r.Spec.Release)
+	if r.Spec.Replicas == nil {
+		replicas := ReplicasDefault
+		r.Spec.Replicas = &replicas
+	}
+	if r.Spec.Resources.Requests == nil && r.Spec.Resources.Limits == nil {
+		r.Spec.Resources = *ResourcesDefault.DeepCopy()
+	}
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-openstack-osop-org-v1alpha1-keystoneserver,mutating=false,failurePolicy=fail,groups=openstack.osop.org,resources=keystoneservers,versions=v1alpha1,name=vkeystoneserver.kb.io
+
+var _ webhook.Validator = &KeystoneServer{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *KeystoneServer) ValidateCreate() error {
+	keystoneserverlog.Info("validate create", "name", r.Name)
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *KeystoneServer) ValidateUpdate(old runtime.Object) error {
+	keystoneserverlog.Info("validate update", "name", r.Name)
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *KeystoneServer) ValidateDelete() error {
+	return nil
+}
+
+func (r *KeystoneServer) validate() error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if r.Spec.Image != "" && strings.TrimSpace(r.Spec.Image) == "" {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("image"), r.Spec.Image, "image must not be blank"))
+	}
+	if r.Spec.Replicas != nil && *r.Spec.Replicas < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), *r.Spec.Replicas, "must be greater than or equal to 0"))
+	}
+
+	if _, ok := ReleaseConfigOptions[NormalizeRelease(r.Spec.Release)]; !ok {
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("release"), r.Spec.Release, Releases))
+	}
+	if r.Annotations[AllowUnknownConfigAnnotation] != "true" {
+		allErrs = append(allErrs, validateConfigOptions(r.Spec.Config, NormalizeRelease(r.Spec.Release), specPath.Child("config"))...)
+	}
+	if _, ok := r.Spec.Config["database"]["connection"]; !ok && r.Spec.Database == nil {
+		allErrs = append(allErrs, field.Required(specPath.Child("database"), "database must be set unless config sets [database] connection"))
+	}
+	if cache := r.Spec.Cache; cache != nil && cache.ServiceRef != nil && len(cache.Servers) > 0 {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("cache", "servers"), "servers and serviceRef are mutually exclusive"))
+	}
+	allErrs = append(allErrs, validateConfigRemove(r.Spec.ConfigRemove, r.Spec.Config, specPath.Child("configRemove"))...)
+	allErrs = append(allErrs, validatePolicy(r.Spec.Policy, specPath.Child("policy"))...)
+	allErrs = append(allErrs, validateSettings(r.Spec.Settings, specPath.Child("settings"))...)
+	allErrs = append(allErrs, validateFernet(r.Spec.Fernet, tokenExpiration(r.Spec), specPath.Child("fernet"))...)
+	allErrs = append(allErrs, validateDomains(r.Spec.Domains, specPath.Child("domains"))...)
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "KeystoneServer"}, r.Name, allErrs)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateConfigRemove rejects removals of options which are set in config at the same time
+func validateConfigRemove(remove []ConfigKey, config osconf.IniFile, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, key := range remove {
+		idxPath := fldPath.Index(i)
+		if strings.TrimSpace(key.Section) == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("section"), "section must not be empty"))
+			continue
+		}
+		options, ok := config[key.Section]
+		if !ok {
+			continue
+		}
+		if key.Key == "" {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("section"), key.Section, "section is also set in config"))
+		} else if _, ok := options[key.Key]; ok {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("key"), key.Key, "option is also set in config"))
+		}
+	}
+	return allErrs
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// DomainConfigSections are the only sections keystone reads from domain-specific configuration files
+var DomainConfigSections = []string{"identity", "ldap"}
+
+// DomainConfigFilename is the file name keystone expects for a domain in domain_config_dir
+func DomainConfigFilename(domain string) string {
+	return fmt.Sprintf("keystone.%s.conf", domain)
+}
+
+// validateDomains rejects domains which can not be rendered into distinct keystone.<name>.conf files
+func validateDomains(domains []DomainSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	names := map[string]bool{}
+	for i, domain := range domains {
+		idxPath := fldPath.Index(i)
+		msgs := validation.IsConfigMapKey(DomainConfigFilename(domain.Name))
+		switch {
+		case strings.TrimSpace(domain.Name) == "":
+			allErrs = append(allErrs, field.Required(idxPath.Child("name"), "domain name must not be empty"))
+		case len(msgs) > 0:
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), domain.Name, strings.Join(msgs, ", ")))
+		case names[domain.Name]:
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), domain.Name))
+		}
+		names[domain.Name] = true
+
+		sections := make([]string, 0, len(domain.Config))
+		for section := range domain.Config {
+			sections = append(sections, section)
+		}
+		sort.Strings(sections)
+		for _, section := range sections {
+			if !containsString(DomainConfigSections, section) {
+				allErrs = append(allErrs, field.NotSupported(idxPath.Child("config").Key(section), section, DomainConfigSections))
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateSettings rejects durations which keystone would get as zero or negative seconds
+func validateSettings(settings *KeystoneSettings, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if settings == nil {
+		return allErrs
+	}
+	validateSeconds := func(d *metav1.Duration, fldPath *field.Path) {
+		if d != nil && d.Duration < time.Second {
+			allErrs = append(allErrs, field.Invalid(fldPath, d.Duration.String(), "must be at least 1s"))
+		}
+	}
+	if settings.Token != nil {
+		validateSeconds(settings.Token.Expiration, fldPath.Child("token", "expiration"))
+	}
+	if settings.Lockout != nil {
+		validateSeconds(settings.Lockout.Duration, fldPath.Child("lockout", "duration"))
+	}
+	return allErrs
+}
+
+// tokenExpiration is the [token] expiration keystone is configured with, raw config is applied last
+func tokenExpiration(spec KeystoneServerSpec) time.Duration {
+	if value, ok := spec.Config["token"]["expiration"]; ok {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	if spec.Settings != nil && spec.Settings.Token != nil && spec.Settings.Token.Expiration != nil {
+		return spec.Settings.Token.Expiration.Duration
+	}
+	return TokenExpirationDefault
+}
+
+// validateFernet rejects key counts which purge keys still needed to validate unexpired tokens,
+// keystone needs a staged and a primary key plus one secondary key per rotation within token expiration
+func validateFernet(fernet FernetSpec, expiration time.Duration, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if fernet.RotationInterval == nil || fernet.RotationInterval.Duration <= 0 {
+		return allErrs
+	}
+	interval := fernet.RotationInterval.Duration
+	minKeys := int32((expiration+interval-1)/interval) + 2
+
+	maxActiveKeys := FernetMaxActiveKeysDefault
+	if fernet.MaxActiveKeys != nil {
+		maxActiveKeys = *fernet.MaxActiveKeys
+	}
+	if maxActiveKeys < minKeys {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxActiveKeys"), maxActiveKeys,
+			fmt.Sprintf("must be at least %d to keep tokens valid for %s with keys rotated every %s", minKeys, expiration, interval)))
+	}
+	return allErrs
+}
+
+// validatePolicy rejects rules which oslo.policy would fail to parse
+func validatePolicy(policy osconf.Policy, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for _, name := range sortedKeys(policy) {
+		if strings.TrimSpace(name) == "" {
+			allErrs = append(allErrs, field.Invalid(fldPath.Key(name), name, "rule name must not be empty"))
+			continue
+		}
+		if err := parsePolicyRule(policy[name]); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Key(name), policy[name], err.Error()))
+		}
+	}
+	return allErrs
+}
+
+// policyTokens splits a rule the way oslo.policy does, parentheses stuck to checks become tokens
+func policyTokens(rule string) []string {
+	var tokens []string
+	for _, tok := range strings.Fields(rule) {
+		trimmed := strings.TrimLeft(tok, "(")
+		for i := 0; i < len(tok)-len(trimmed); i++ {
+			tokens = append(tokens, "(")
+		}
+		clean := strings.TrimRight(trimmed, ")")
+		if clean != "" {
+			tokens = append(tokens, clean)
+		}
+		for i := 0; i < len(trimmed)-len(clean); i++ {
+			tokens = append(tokens, ")")
+		}
+	}
+	return tokens
+}
+
+// policyParser is a recursive descent parser of the oslo.policy rule grammar:
+//
+//	expr   = term { "or" term }
+//	term   = factor { "and" factor }
+//	factor = "not" factor | "(" expr ")" | check
+type policyParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *policyParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *policyParser) expr() error {
+	if err := p.term(); err != nil {
+		return err
+	}
+	for strings.ToLower(p.peek()) == "or" {
+		p.pos++
+		if err := p.term(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *policyParser) term() error {
+	if err := p.factor(); err != nil {
+		return err
+	}
+	for strings.ToLower(p.peek()) == "and" {
+		p.pos++
+		if err := p.factor(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *policyParser) factor() error {
+	tok := p.peek()
+	switch {
+	case tok == "":
+		return fmt.Errorf("unexpected end of rule")
+	case strings.ToLower(tok) == "not":
+		p.pos++
+		return p.factor()
+	case tok == "(":
+		p.pos++
+		if err := p.expr(); err != nil {
+			return err
+		}
+		if p.peek() != ")" {
+			return fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return nil
+	case tok == ")", strings.ToLower(tok) == "and", strings.ToLower(tok) == "or":
+		return fmt.Errorf("unexpected %q", tok)
+	}
+	p.pos++
+	return checkPolicyCheck(tok)
+}
+
+// checkPolicyCheck accepts "@", "!" and kind:match checks
+func checkPolicyCheck(check string) error {
+	if check == "@" || check == "!" {
+		return nil
+	}
+	idx := strings.Index(check, ":")
+	if idx <= 0 {
+		return fmt.Errorf("check %q is not in kind:match form", check)
+	}
+	if idx == len(check)-1 {
+		return fmt.Errorf("check %q has nothing to match", check)
+	}
+	return nil
+}
+
+// parsePolicyRule validates rule syntax, an empty rule always allows access
+func parsePolicyRule(rule string) error {
+	tokens := policyTokens(rule)
+	if len(tokens) == 0 {
+		return nil
+	}
+	p := &policyParser{tokens: tokens}
+	if err := p.expr(); err != nil {
+		return err
+	}
+	if p.pos != len(tokens) {
+		return fmt.Errorf("unexpected %q", tokens[p.pos])
+	}
+	return nil
+}
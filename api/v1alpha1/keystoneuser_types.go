@@ -0,0 +1,78 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KeystoneUserSpec defines the desired state of KeystoneUser
+type KeystoneUserSpec struct {
+	// ServerRef references the KeystoneServer managing the user, its bootstrap admin is used
+	ServerRef corev1.LocalObjectReference `json:"serverRef"`
+	// Name of the user in keystone, the object name is used if empty
+	Name string `json:"name,omitempty"`
+	// Adopt takes over an existing keystone user with the same name, adopted users are
+	// updated to match the spec but never removed from keystone
+	Adopt bool `json:"adopt,omitempty"`
+	// Domain is the name of the user domain, "Default" if empty
+	Domain      string `json:"domain,omitempty"`
+	Description string `json:"description,omitempty"`
+	Email       string `json:"email,omitempty"`
+	// DefaultProject is the name of a project in the user domain
+	DefaultProject string `json:"defaultProject,omitempty"`
+	// PasswordSecretRef selects the key of a Secret holding the user password,
+	// the password is set again each time the Secret changes
+	PasswordSecretRef *corev1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// KeystoneUserStatus defines the observed state of KeystoneUser
+type KeystoneUserStatus struct {
+	IdentityStatus `json:",inline"`
+	// ID of the user in keystone
+	ID string `json:"id,omitempty"`
+	// PasswordResourceVersion is the password Secret version the keystone password was last set from
+	PasswordResourceVersion string `json:"passwordResourceVersion,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// KeystoneUser is the Schema for the keystoneusers API. An existing keystone user with
+// the same name is only taken over if spec.adopt is set.
+type KeystoneUser struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KeystoneUserSpec   `json:"spec,omitempty"`
+	Status KeystoneUserStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KeystoneUserList contains a list of KeystoneUser
+type KeystoneUserList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KeystoneUser `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KeystoneUser{}, &KeystoneUserList{})
+}
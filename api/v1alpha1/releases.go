@@ -0,0 +1,39 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import "strings"
+
+// DefaultRelease is used when spec.release is not set
+const DefaultRelease = "stein"
+
+// Releases lists supported releases ordered from the oldest to the newest one
+var Releases = []string{"stein", "train", "ussuri"}
+
+// ReleaseImages holds default keystone images keyed by release name
+var ReleaseImages = map[string]string{
+	"stein":  "docker.io/openstackhelm/keystone:stein-ubuntu_bionic",
+	"train":  "docker.io/openstackhelm/keystone:train-ubuntu_bionic",
+	"ussuri": "docker.io/openstackhelm/keystone:ussuri-ubuntu_bionic",
+}
+
+// NormalizeRelease maps a user provided release name to a Releases entry
+func NormalizeRelease(release string) string {
+	if release == "" {
+		return DefaultRelease
+	}
+	return strings.ToLower(release)
+}
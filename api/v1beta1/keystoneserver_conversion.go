@@ -0,0 +1,274 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+// Sub-objects shared by both versions have identical layouts and are converted with Go type
+// conversions, so a field added to only one of the versions breaks the build instead of being
+// silently dropped during conversion.
+
+var _ conversion.Convertible = &KeystoneServer{}
+
+// DatabaseClientImageAnnotation keeps the v1alpha1 database client image of objects without an
+// admin secret, which this version only has a place for under spec.database.provisioning
+const DatabaseClientImageAnnotation = "openstack.osop.org/database-client-image"
+
+// ConvertTo converts this KeystoneServer to the hub version (v1alpha1)
+func (src *KeystoneServer) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.KeystoneServer)
+	dst.ObjectMeta = src.ObjectMeta
+	clientImage, hasClientImage := src.Annotations[DatabaseClientImageAnnotation]
+	if hasClientImage {
+		dst.Annotations = copyAnnotations(src.Annotations)
+		delete(dst.Annotations, DatabaseClientImageAnnotation)
+		if len(dst.Annotations) == 0 {
+			dst.Annotations = nil
+		}
+	}
+
+	spec := src.Spec
+	dst.Spec = v1alpha1.KeystoneServerSpec{
+		Image:                   spec.Image,
+		Release:                 spec.Release,
+		Replicas:                spec.Replicas,
+		Resources:               spec.Resources,
+		Config:                  spec.Config.Options,
+		Policy:                  spec.Config.Policy,
+		PolicyHotReload:         spec.Config.PolicyHotReload,
+		SensitiveConfigInSecret: spec.Config.SensitiveInSecret,
+		Settings:                settingsToHub(spec.Config.Settings),
+		ConfigRemove:            configKeysToHub(spec.Config.Remove),
+		Fernet:                  v1alpha1.FernetSpec(spec.Fernet),
+		Credential:              v1alpha1.CredentialSpec(spec.Credential),
+		Service:                 v1alpha1.ServiceSpec(spec.Service),
+	}
+	if spec.Bootstrap != nil {
+		bootstrap := v1alpha1.BootstrapSpec(*spec.Bootstrap)
+		dst.Spec.Bootstrap = &bootstrap
+	}
+	if spec.Ingress != nil {
+		ingress := v1alpha1.IngressSpec(*spec.Ingress)
+		dst.Spec.Ingress = &ingress
+	}
+	if spec.Database != nil {
+		dst.Spec.Database = &v1alpha1.DatabaseSpec{
+			Host:              spec.Database.Host,
+			Port:              spec.Database.Port,
+			Name:              spec.Database.Name,
+			User:              spec.Database.User,
+			PasswordSecretRef: spec.Database.PasswordSecretRef,
+		}
+		if provisioning := spec.Database.Provisioning; provisioning != nil {
+			adminSecretRef := provisioning.AdminSecretRef
+			dst.Spec.Database.AdminSecretRef = &adminSecretRef
+			dst.Spec.Database.ClientImage = provisioning.ClientImage
+		} else if hasClientImage {
+			dst.Spec.Database.ClientImage = clientImage
+		}
+	}
+	if spec.Messaging != nil {
+		dst.Spec.Messaging = &v1alpha1.MessagingSpec{
+			Hosts:             spec.Messaging.Hosts,
+			VHost:             spec.Messaging.VHost,
+			User:              spec.Messaging.User,
+			PasswordSecretRef: spec.Messaging.PasswordSecretRef,
+		}
+		if spec.Messaging.TLS != nil {
+			tls := v1alpha1.MessagingTLSSpec(*spec.Messaging.TLS)
+			dst.Spec.Messaging.TLS = &tls
+		}
+	}
+	if spec.Cache != nil {
+		dst.Spec.Cache = &v1alpha1.CacheSpec{Servers: spec.Cache.Servers}
+		if spec.Cache.ServiceRef != nil {
+			serviceRef := v1alpha1.CacheServiceRef(*spec.Cache.ServiceRef)
+			dst.Spec.Cache.ServiceRef = &serviceRef
+		}
+	}
+	for _, domain := range spec.Domains {
+		dst.Spec.Domains = append(dst.Spec.Domains, v1alpha1.DomainSpec(domain))
+	}
+
+	status := src.Status
+	dst.Status = v1alpha1.KeystoneServerStatus{
+		ObservedGeneration: status.ObservedGeneration,
+		AvailableReplicas:  status.AvailableReplicas,
+		UpdatedReplicas:    status.UpdatedReplicas,
+		Image:              status.Image,
+		FernetKeys:         v1alpha1.FernetKeysStatus(status.FernetKeys),
+		CredentialKeys:     v1alpha1.CredentialKeysStatus(status.CredentialKeys),
+		Database:           v1alpha1.DatabaseStatus(status.Database),
+		Bootstrap:          v1alpha1.BootstrapStatus(status.Bootstrap),
+		Upgrade:            v1alpha1.UpgradeStatus(status.Upgrade),
+		ServiceDNSName:     status.ServiceDNSName,
+		OverriddenOptions:  status.OverriddenOptions,
+	}
+	for _, cond := range status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, v1alpha1.Condition(cond))
+	}
+	return nil
+}
+
+func settingsToHub(in *KeystoneSettings) *v1alpha1.KeystoneSettings {
+	if in == nil {
+		return nil
+	}
+	return &v1alpha1.KeystoneSettings{
+		MaxTokenSize:   in.MaxTokenSize,
+		Token:          (*v1alpha1.TokenSettings)(in.Token),
+		Lockout:        (*v1alpha1.LockoutSettings)(in.Lockout),
+		PasswordPolicy: (*v1alpha1.PasswordPolicySettings)(in.PasswordPolicy),
+	}
+}
+
+func copyAnnotations(in map[string]string) map[string]string {
+	out := make(map[string]string, len(in)+1)
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+func configKeysToHub(in []ConfigKey) []v1alpha1.ConfigKey {
+	if in == nil {
+		return nil
+	}
+	out := make([]v1alpha1.ConfigKey, 0, len(in))
+	for _, key := range in {
+		out = append(out, v1alpha1.ConfigKey(key))
+	}
+	return out
+}
+
+// ConvertFrom converts from the hub version (v1alpha1) to this version
+func (dst *KeystoneServer) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.KeystoneServer)
+	dst.ObjectMeta = src.ObjectMeta
+
+	spec := src.Spec
+	dst.Spec = KeystoneServerSpec{
+		Image:     spec.Image,
+		Release:   spec.Release,
+		Replicas:  spec.Replicas,
+		Resources: spec.Resources,
+		Config: ConfigSpec{
+			Options:           spec.Config,
+			Remove:            configKeysFromHub(spec.ConfigRemove),
+			Policy:            spec.Policy,
+			PolicyHotReload:   spec.PolicyHotReload,
+			SensitiveInSecret: spec.SensitiveConfigInSecret,
+			Settings:          settingsFromHub(spec.Settings),
+		},
+		Fernet:     FernetSpec(spec.Fernet),
+		Credential: CredentialSpec(spec.Credential),
+		Service:    ServiceSpec(spec.Service),
+	}
+	if spec.Bootstrap != nil {
+		bootstrap := BootstrapSpec(*spec.Bootstrap)
+		dst.Spec.Bootstrap = &bootstrap
+	}
+	if spec.Ingress != nil {
+		ingress := IngressSpec(*spec.Ingress)
+		dst.Spec.Ingress = &ingress
+	}
+	if spec.Database != nil {
+		dst.Spec.Database = &DatabaseSpec{
+			Host:              spec.Database.Host,
+			Port:              spec.Database.Port,
+			Name:              spec.Database.Name,
+			User:              spec.Database.User,
+			PasswordSecretRef: spec.Database.PasswordSecretRef,
+		}
+		if spec.Database.AdminSecretRef != nil {
+			dst.Spec.Database.Provisioning = &DatabaseProvisioningSpec{
+				AdminSecretRef: *spec.Database.AdminSecretRef,
+				ClientImage:    spec.Database.ClientImage,
+			}
+		} else if spec.Database.ClientImage != "" {
+			dst.Annotations = copyAnnotations(src.Annotations)
+			dst.Annotations[DatabaseClientImageAnnotation] = spec.Database.ClientImage
+		}
+	}
+	if spec.Messaging != nil {
+		dst.Spec.Messaging = &MessagingSpec{
+			Hosts:             spec.Messaging.Hosts,
+			VHost:             spec.Messaging.VHost,
+			User:              spec.Messaging.User,
+			PasswordSecretRef: spec.Messaging.PasswordSecretRef,
+		}
+		if spec.Messaging.TLS != nil {
+			tls := MessagingTLSSpec(*spec.Messaging.TLS)
+			dst.Spec.Messaging.TLS = &tls
+		}
+	}
+	if spec.Cache != nil {
+		dst.Spec.Cache = &CacheSpec{Servers: spec.Cache.Servers}
+		if spec.Cache.ServiceRef != nil {
+			serviceRef := CacheServiceRef(*spec.Cache.ServiceRef)
+			dst.Spec.Cache.ServiceRef = &serviceRef
+		}
+	}
+	for _, domain := range spec.Domains {
+		dst.Spec.Domains = append(dst.Spec.Domains, DomainSpec(domain))
+	}
+
+	status := src.Status
+	dst.Status = KeystoneServerStatus{
+		ObservedGeneration: status.ObservedGeneration,
+		AvailableReplicas:  status.AvailableReplicas,
+		UpdatedReplicas:    status.UpdatedReplicas,
+		Image:              status.Image,
+		FernetKeys:         FernetKeysStatus(status.FernetKeys),
+		CredentialKeys:     CredentialKeysStatus(status.CredentialKeys),
+		Database:           DatabaseStatus(status.Database),
+		Bootstrap:          BootstrapStatus(status.Bootstrap),
+		Upgrade:            UpgradeStatus(status.Upgrade),
+		ServiceDNSName:     status.ServiceDNSName,
+		OverriddenOptions:  status.OverriddenOptions,
+	}
+	for _, cond := range status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, Condition(cond))
+	}
+	return nil
+}
+
+func settingsFromHub(in *v1alpha1.KeystoneSettings) *KeystoneSettings {
+	if in == nil {
+		return nil
+	}
+	return &KeystoneSettings{
+		MaxTokenSize:   in.MaxTokenSize,
+		Token:          (*TokenSettings)(in.Token),
+		Lockout:        (*LockoutSettings)(in.Lockout),
+		PasswordPolicy: (*PasswordPolicySettings)(in.PasswordPolicy),
+	}
+}
+
+func configKeysFromHub(in []v1alpha1.ConfigKey) []ConfigKey {
+	if in == nil {
+		return nil
+	}
+	out := make([]ConfigKey, 0, len(in))
+	for _, key := range in {
+		out = append(out, ConfigKey(key))
+	}
+	return out
+}
@@ -0,0 +1,172 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	osconf "github.com/dukov/osop-common/pkg/openstack/config"
+	fuzz "github.com/google/gofuzz"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+func newHubKeystoneServer() *v1alpha1.KeystoneServer {
+	replicas := int32(3)
+	maxActiveKeys := int32(5)
+	failureAttempts := int32(3)
+	now := metav1.NewTime(time.Unix(1600000000, 0))
+	passwordRef := corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "passwords"}, Key: "db"}
+
+	return &v1alpha1.KeystoneServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "keystone", Namespace: "openstack", Generation: 4},
+		Spec: v1alpha1.KeystoneServerSpec{
+			Image:    "docker.io/openstackhelm/keystone:train-ubuntu_bionic",
+			Release:  "train",
+			Replicas: &replicas,
+			Config:   osconf.IniFile{"token": {"expiration": "3600"}},
+			Policy:   osconf.Policy{"identity:list_users": "role:admin"},
+			Settings: &v1alpha1.KeystoneSettings{
+				Token:          &v1alpha1.TokenSettings{Expiration: &metav1.Duration{Duration: 2 * time.Hour}},
+				Lockout:        &v1alpha1.LockoutSettings{FailureAttempts: &failureAttempts},
+				PasswordPolicy: &v1alpha1.PasswordPolicySettings{Regex: "^.{12,}$"},
+			},
+			ConfigRemove: []v1alpha1.ConfigKey{
+				{Section: "oslo_messaging_rabbit", Key: "rabbit_ha_queues"},
+				{Section: "identity"},
+			},
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			},
+			PolicyHotReload:         true,
+			SensitiveConfigInSecret: true,
+			Fernet:                  v1alpha1.FernetSpec{RotationInterval: &metav1.Duration{Duration: time.Hour}, MaxActiveKeys: &maxActiveKeys},
+			Credential:              v1alpha1.CredentialSpec{RotationGeneration: 2},
+			Bootstrap:               &v1alpha1.BootstrapSpec{AdminSecretRef: corev1.LocalObjectReference{Name: "admin"}, Region: "RegionTwo"},
+			Service:                 v1alpha1.ServiceSpec{Type: corev1.ServiceTypeNodePort, NodePort: 30500},
+			Ingress:                 &v1alpha1.IngressSpec{Host: "keystone.example.com", TLSSecretName: "keystone-tls"},
+			Database: &v1alpha1.DatabaseSpec{
+				Host:              "mariadb",
+				PasswordSecretRef: passwordRef,
+				AdminSecretRef:    &corev1.LocalObjectReference{Name: "mariadb-root"},
+				ClientImage:       "mariadb:10.5",
+			},
+			Messaging: &v1alpha1.MessagingSpec{
+				Hosts:             []string{"rabbitmq"},
+				User:              "keystone",
+				PasswordSecretRef: passwordRef,
+				TLS:               &v1alpha1.MessagingTLSSpec{CASecretName: "rabbitmq-ca"},
+			},
+			Cache: &v1alpha1.CacheSpec{ServiceRef: &v1alpha1.CacheServiceRef{Name: "memcached"}},
+			Domains: []v1alpha1.DomainSpec{{
+				Name:          "corp",
+				Config:        osconf.IniFile{"ldap": {"url": "ldap://ldap.example.com"}},
+				BindSecretRef: &corev1.LocalObjectReference{Name: "corp-ldap"},
+			}},
+		},
+		Status: v1alpha1.KeystoneServerStatus{
+			ObservedGeneration: 4,
+			Conditions: []v1alpha1.Condition{
+				{Type: v1alpha1.ConditionReady, Status: corev1.ConditionTrue, LastTransitionTime: now, Reason: "Ready"},
+			},
+			AvailableReplicas: 3,
+			Image:             "docker.io/openstackhelm/keystone:train-ubuntu_bionic",
+			FernetKeys:        v1alpha1.FernetKeysStatus{KeyCount: 5, LastRotationTime: &now},
+			Database:          v1alpha1.DatabaseStatus{SyncedRelease: "train", ProvisionJob: "keystone-db-provision-1"},
+			Upgrade:           v1alpha1.UpgradeStatus{FromRelease: "stein", ToRelease: "train", Phase: v1alpha1.UpgradePhaseCompleted},
+			ServiceDNSName:    "keystone.openstack.svc",
+			OverriddenOptions: []string{"[token] expiration"},
+		},
+	}
+}
+
+var _ = Describe("KeystoneServer conversion", func() {
+	It("keeps every field when converting from the hub and back", func() {
+		hub := newHubKeystoneServer()
+
+		var spoke KeystoneServer
+		Expect(spoke.ConvertFrom(hub.DeepCopy())).To(Succeed())
+		Expect(spoke.Spec.Config.Options).To(Equal(hub.Spec.Config))
+		Expect(spoke.Spec.Config.PolicyHotReload).To(BeTrue())
+		Expect(*spoke.Spec.Config.Settings.Lockout.FailureAttempts).To(Equal(int32(3)))
+		Expect(spoke.Spec.Database.Provisioning.AdminSecretRef.Name).To(Equal("mariadb-root"))
+
+		var back v1alpha1.KeystoneServer
+		Expect(spoke.ConvertTo(&back)).To(Succeed())
+		Expect(&back).To(Equal(hub))
+	})
+
+	It("keeps every field when converting to the hub and back", func() {
+		var spoke KeystoneServer
+		Expect(spoke.ConvertFrom(newHubKeystoneServer())).To(Succeed())
+
+		var hub v1alpha1.KeystoneServer
+		Expect(spoke.DeepCopy().ConvertTo(&hub)).To(Succeed())
+		var back KeystoneServer
+		Expect(back.ConvertFrom(&hub)).To(Succeed())
+		Expect(back).To(Equal(spoke))
+	})
+
+	It("keeps the database client image of objects without an admin secret", func() {
+		hub := newHubKeystoneServer()
+		hub.Spec.Database.AdminSecretRef = nil
+
+		var spoke KeystoneServer
+		Expect(spoke.ConvertFrom(hub.DeepCopy())).To(Succeed())
+		Expect(spoke.Spec.Database.Provisioning).To(BeNil())
+		Expect(spoke.Annotations).To(HaveKeyWithValue(DatabaseClientImageAnnotation, "mariadb:10.5"))
+
+		var back v1alpha1.KeystoneServer
+		Expect(spoke.ConvertTo(&back)).To(Succeed())
+		Expect(&back).To(Equal(hub))
+	})
+
+	It("keeps every field of randomly filled objects when converting from the hub and back", func() {
+		f := fuzz.New().NilChance(0.3)
+		for i := 0; i < 500; i++ {
+			hub := &v1alpha1.KeystoneServer{}
+			f.Fuzz(hub)
+			// apiVersion and kind are set by the conversion webhook, not by the converters
+			hub.TypeMeta = metav1.TypeMeta{}
+
+			var spoke KeystoneServer
+			Expect(spoke.ConvertFrom(hub.DeepCopy())).To(Succeed())
+			var back v1alpha1.KeystoneServer
+			Expect(spoke.ConvertTo(&back)).To(Succeed())
+			Expect(&back).To(Equal(hub))
+		}
+	})
+
+	It("converts a minimal object without inventing optional sub-objects", func() {
+		hub := &v1alpha1.KeystoneServer{ObjectMeta: metav1.ObjectMeta{Name: "keystone"}}
+
+		var spoke KeystoneServer
+		Expect(spoke.ConvertFrom(hub)).To(Succeed())
+		Expect(spoke.Spec.Database).To(BeNil())
+		Expect(spoke.Spec.Messaging).To(BeNil())
+		Expect(spoke.Spec.Cache).To(BeNil())
+
+		var back v1alpha1.KeystoneServer
+		Expect(spoke.ConvertTo(&back)).To(Succeed())
+		Expect(&back).To(Equal(hub))
+	})
+})
@@ -0,0 +1,121 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+func valueOrDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// bootstrapEnv translates the bootstrap spec into keystone-manage bootstrap environment
+func bootstrapEnv(spec openstackv1alpha1.BootstrapSpec, secret corev1.Secret) []corev1.EnvVar {
+	secretEnv := func(name, key string) corev1.EnvVar {
+		optional := key != openstackv1alpha1.AdminPasswordKey
+		return corev1.EnvVar{
+			Name: name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: spec.AdminSecretRef,
+					Key:                  key,
+					Optional:             &optional,
+				},
+			},
+		}
+	}
+
+	env := []corev1.EnvVar{
+		secretEnv("OS_BOOTSTRAP_PASSWORD", openstackv1alpha1.AdminPasswordKey),
+		{Name: "OS_BOOTSTRAP_PROJECT_NAME", Value: valueOrDefault(spec.AdminProject, BootstrapAdminProjectDefault)},
+		{Name: "OS_BOOTSTRAP_ROLE_NAME", Value: valueOrDefault(spec.AdminRole, BootstrapAdminRoleDefault)},
+		{Name: "OS_BOOTSTRAP_REGION_ID", Value: valueOrDefault(spec.Region, BootstrapRegionDefault)},
+	}
+	if _, ok := secret.Data[openstackv1alpha1.AdminUsernameKey]; ok {
+		env = append(env, secretEnv("OS_BOOTSTRAP_USERNAME", openstackv1alpha1.AdminUsernameKey))
+	} else {
+		env = append(env, corev1.EnvVar{Name: "OS_BOOTSTRAP_USERNAME", Value: BootstrapAdminUsernameDefault})
+	}
+
+	urls := map[string]string{
+		"OS_BOOTSTRAP_PUBLIC_URL":   spec.PublicURL,
+		"OS_BOOTSTRAP_INTERNAL_URL": valueOrDefault(spec.InternalURL, spec.PublicURL),
+		"OS_BOOTSTRAP_ADMIN_URL":    valueOrDefault(spec.AdminURL, spec.PublicURL),
+	}
+	for _, name := range []string{"OS_BOOTSTRAP_PUBLIC_URL", "OS_BOOTSTRAP_INTERNAL_URL", "OS_BOOTSTRAP_ADMIN_URL"} {
+		if urls[name] != "" {
+			env = append(env, corev1.EnvVar{Name: name, Value: urls[name]})
+		}
+	}
+	return env
+}
+
+// bootstrapJobName changes whenever bootstrap parameters or admin credentials change
+// so keystone-manage bootstrap runs again, it is idempotent and resets the admin password
+func bootstrapJobName(srv openstackv1alpha1.KeystoneServer, secret corev1.Secret) string {
+	spec := srv.Spec.Bootstrap
+	return srv.Name + "-bootstrap-" + hashStrings(
+		spec.AdminSecretRef.Name,
+		secret.ResourceVersion,
+		spec.AdminProject,
+		spec.AdminRole,
+		spec.Region,
+		spec.PublicURL,
+		spec.InternalURL,
+		spec.AdminURL,
+	)
+}
+
+// bootstrap runs keystone-manage bootstrap creating the admin user, project, role and identity endpoints.
+// It reports whether the latest bootstrap parameters have been applied.
+func (r *KeystoneServerReconciler) bootstrap(ctx context.Context, srv *openstackv1alpha1.KeystoneServer) (bool, error) {
+	if srv.Spec.Bootstrap == nil {
+		return true, nil
+	}
+
+	var secret corev1.Secret
+	key := types.NamespacedName{Namespace: srv.Namespace, Name: srv.Spec.Bootstrap.AdminSecretRef.Name}
+	if err := r.Get(ctx, key, &secret); err != nil {
+		return false, err
+	}
+
+	name := bootstrapJobName(*srv, secret)
+	if srv.Status.Bootstrap.Job == name {
+		return true, nil
+	}
+
+	job, err := r.createJob(*srv, name, []string{"keystone-manage", "bootstrap"})
+	if err != nil {
+		return false, err
+	}
+	job.Spec.Template.Spec.Containers[0].Env = append(job.Spec.Template.Spec.Containers[0].Env, bootstrapEnv(*srv.Spec.Bootstrap, secret)...)
+
+	done, err := r.runJob(ctx, job)
+	if err != nil || !done {
+		return false, err
+	}
+	srv.Status.Bootstrap.Job = name
+	return true, nil
+}
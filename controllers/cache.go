@@ -0,0 +1,130 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	osconf "github.com/dukov/osop-common/pkg/openstack/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+var cacheServiceKey = ".spec.cache.serviceRef.name"
+
+// endpointAddresses returns sorted host:port pairs of ready endpoints serving the named port.
+// Endpoints with a hostname, such as StatefulSet pods behind a headless Service, are addressed
+// by their stable DNS name so rescheduled pods do not change the rendered config.
+func endpointAddresses(endpoints corev1.Endpoints, portName string) []string {
+	addresses := []string{}
+	for _, subset := range endpoints.Subsets {
+		var port *corev1.EndpointPort
+		for i := range subset.Ports {
+			if portName == "" || subset.Ports[i].Name == portName {
+				port = &subset.Ports[i]
+				break
+			}
+		}
+		if port == nil {
+			continue
+		}
+		for _, address := range subset.Addresses {
+			host := address.IP
+			if address.Hostname != "" {
+				host = fmt.Sprintf("%s.%s.%s.svc", address.Hostname, endpoints.Name, endpoints.Namespace)
+			}
+			addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(int(port.Port))))
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// resolveCacheServers replaces cache servers with ready endpoints of the referenced Service
+func (r *KeystoneServerReconciler) resolveCacheServers(ctx context.Context, srv *openstackv1alpha1.KeystoneServer) error {
+	cache := srv.Spec.Cache
+	if cache == nil || cache.ServiceRef == nil {
+		return nil
+	}
+
+	var endpoints corev1.Endpoints
+	key := types.NamespacedName{Namespace: srv.Namespace, Name: cache.ServiceRef.Name}
+	if err := r.Get(ctx, key, &endpoints); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	cache.Servers = endpointAddresses(endpoints, cache.ServiceRef.Port)
+	return nil
+}
+
+// cacheConfig enables caching only when there are memcached servers to use
+func cacheConfig(srv openstackv1alpha1.KeystoneServer) osconf.IniFile {
+	cache := srv.Spec.Cache
+	if cache == nil {
+		if _, ok := srv.Spec.Config["cache"]["memcache_servers"]; ok {
+			return osconf.IniFile{}
+		}
+		return osconf.IniFile{"cache": {"enabled": "false"}}
+	}
+	if len(cache.Servers) == 0 {
+		return osconf.IniFile{"cache": {"enabled": "false"}}
+	}
+
+	servers := make([]string, 0, len(cache.Servers))
+	for _, server := range cache.Servers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, strconv.Itoa(MemcachedPortDefault))
+		}
+		servers = append(servers, server)
+	}
+	return osconf.IniFile{"cache": {"enabled": "true", "memcache_servers": strings.Join(servers, ",")}}
+}
+
+func indexCacheService(rawObj runtime.Object) []string {
+	srv, ok := rawObj.(*openstackv1alpha1.KeystoneServer)
+	if !ok || srv.Spec.Cache == nil || srv.Spec.Cache.ServiceRef == nil {
+		return nil
+	}
+	return []string{srv.Spec.Cache.ServiceRef.Name}
+}
+
+// cacheServiceRequests maps Endpoints to KeystoneServers using them as cache servers
+func (r *KeystoneServerReconciler) cacheServiceRequests(obj handler.MapObject) []reconcile.Request {
+	var servers openstackv1alpha1.KeystoneServerList
+	if err := r.List(context.Background(), &servers, client.InNamespace(obj.Meta.GetNamespace()),
+		client.MatchingField(cacheServiceKey, obj.Meta.GetName())); err != nil {
+		r.Log.Error(err, "unable to list KeystoneServers using cache Service", "service", obj.Meta.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(servers.Items))
+	for _, srv := range servers.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: srv.Namespace, Name: srv.Name},
+		})
+	}
+	return requests
+}
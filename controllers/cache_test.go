@@ -0,0 +1,97 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	osconf "github.com/dukov/osop-common/pkg/openstack/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+func TestCacheConfig(t *testing.T) {
+	g := NewWithT(t)
+	srv := newKeystoneServer("tenant-a", nil, nil)
+	g.Expect(cacheConfig(srv)).To(Equal(osconf.IniFile{"cache": {"enabled": "false"}}))
+
+	// servers configured through raw config are left alone
+	srv.Spec.Config = osconf.IniFile{"cache": {"memcache_servers": "memcached:11211"}}
+	g.Expect(cacheConfig(srv)).To(BeEmpty())
+
+	srv.Spec.Cache = &openstackv1alpha1.CacheSpec{}
+	g.Expect(cacheConfig(srv)).To(Equal(osconf.IniFile{"cache": {"enabled": "false"}}))
+
+	srv.Spec.Cache.Servers = []string{"memcached-0", "10.0.0.2:11212"}
+	g.Expect(cacheConfig(srv)).To(Equal(osconf.IniFile{"cache": {
+		"enabled":          "true",
+		"memcache_servers": "memcached-0:11211,10.0.0.2:11212",
+	}}))
+}
+
+func TestEndpointAddresses(t *testing.T) {
+	g := NewWithT(t)
+	endpoints := corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "memcached", Namespace: "tenant-a"},
+		Subsets: []corev1.EndpointSubset{{
+			Addresses:         []corev1.EndpointAddress{{IP: "10.0.0.4"}, {IP: "10.0.0.3", Hostname: "memcached-0"}},
+			NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.5"}},
+			Ports:             []corev1.EndpointPort{{Name: "metrics", Port: 9150}, {Name: "memcache", Port: 11211}},
+		}},
+	}
+	g.Expect(endpointAddresses(endpoints, "memcache")).To(Equal([]string{"10.0.0.4:11211", "memcached-0.memcached.tenant-a.svc:11211"}))
+	g.Expect(endpointAddresses(endpoints, "")).To(Equal([]string{"10.0.0.4:9150", "memcached-0.memcached.tenant-a.svc:9150"}))
+	g.Expect(endpointAddresses(endpoints, "missing")).To(BeEmpty())
+	g.Expect(endpointAddresses(corev1.Endpoints{}, "")).To(BeEmpty())
+}
+
+func TestResolveCacheServers(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	srv := newKeystoneServer("tenant-a", nil, nil)
+	srv.Spec.Cache = &openstackv1alpha1.CacheSpec{ServiceRef: &openstackv1alpha1.CacheServiceRef{Name: "memcached", Port: "memcache"}}
+	endpoints := corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "memcached", Namespace: srv.Namespace},
+		Subsets: []corev1.EndpointSubset{{
+			Addresses: []corev1.EndpointAddress{{IP: "10.0.0.3", Hostname: "memcached-0"}, {IP: "10.0.0.4", Hostname: "memcached-1"}},
+			Ports:     []corev1.EndpointPort{{Name: "memcache", Port: 11211}},
+		}},
+	}
+	r := &KeystoneServerReconciler{Client: fake.NewFakeClientWithScheme(newTestScheme(t), &endpoints), Scheme: newTestScheme(t)}
+
+	g.Expect(r.resolveCacheServers(ctx, &srv)).To(Succeed())
+	servers := srv.Spec.Cache.Servers
+	g.Expect(servers).To(Equal([]string{
+		"memcached-0.memcached.tenant-a.svc:11211",
+		"memcached-1.memcached.tenant-a.svc:11211",
+	}))
+
+	// rescheduled pods keep their hostnames and leave the rendered servers unchanged
+	endpoints.Subsets[0].Addresses = []corev1.EndpointAddress{{IP: "10.0.0.8", Hostname: "memcached-1"}, {IP: "10.0.0.7", Hostname: "memcached-0"}}
+	g.Expect(r.Update(ctx, &endpoints)).To(Succeed())
+	g.Expect(r.resolveCacheServers(ctx, &srv)).To(Succeed())
+	g.Expect(srv.Spec.Cache.Servers).To(Equal(servers))
+
+	srv.Spec.Cache.ServiceRef.Name = "missing"
+	g.Expect(r.resolveCacheServers(ctx, &srv)).To(Succeed())
+	g.Expect(srv.Spec.Cache.Servers).To(BeEmpty())
+}
@@ -0,0 +1,168 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	osconf "github.com/dukov/osop-common/pkg/openstack/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+var referencedSecretKey = ".spec.secretRefs"
+
+func configSecretName(srv openstackv1alpha1.KeystoneServer) string {
+	return srv.Name + "-config"
+}
+
+// escapeConfigValue protects values from oslo.config variable substitution
+func escapeConfigValue(value string) string {
+	return strings.Replace(value, "$", "$$", -1)
+}
+
+// readSecretKey returns the value of the selected Secret key
+func (r *KeystoneServerReconciler) readSecretKey(ctx context.Context, namespace string, selector corev1.SecretKeySelector) (string, error) {
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: selector.Name}, &secret); err != nil {
+		return "", err
+	}
+	value, ok := secret.Data[selector.Key]
+	if !ok {
+		return "", fmt.Errorf("secret %s/%s has no %q key", namespace, selector.Name, selector.Key)
+	}
+	return string(value), nil
+}
+
+// databaseConnection builds the SQLAlchemy URL for the [database] connection option
+func databaseConnection(spec openstackv1alpha1.DatabaseSpec, password string) string {
+	port := spec.Port
+	if port == 0 {
+		port = DatabasePortDefault
+	}
+	conn := url.URL{
+		Scheme: "mysql+pymysql",
+		User:   url.UserPassword(valueOrDefault(spec.User, DatabaseUserDefault), password),
+		Host:   net.JoinHostPort(spec.Host, strconv.Itoa(int(port))),
+		Path:   "/" + valueOrDefault(spec.Name, DatabaseNameDefault),
+	}
+	return escapeConfigValue(conn.String())
+}
+
+// createConfigSecret renders keystone.conf.d drop-in files holding credentials,
+// they are mounted next to keystone.conf and never land in the ConfigMap
+func (r *KeystoneServerReconciler) createConfigSecret(ctx context.Context, srv openstackv1alpha1.KeystoneServer, defaults ReleaseDefaults) (corev1.Secret, error) {
+	data := make(map[string][]byte)
+	if rendered := renderConfig(srv, defaults); len(rendered.Sensitive) > 0 {
+		data[SensitiveConfigFilename] = []byte(rendered.Sensitive.ToString())
+	}
+	if srv.Spec.Database != nil {
+		password, err := r.readSecretKey(ctx, srv.Namespace, srv.Spec.Database.PasswordSecretRef)
+		if err != nil {
+			return corev1.Secret{}, err
+		}
+		database := osconf.IniFile{
+			"database": {"connection": databaseConnection(*srv.Spec.Database, password)},
+		}
+		data[DatabaseConfigFilename] = []byte(database.ToString())
+	}
+	if srv.Spec.Messaging != nil {
+		password, err := r.readSecretKey(ctx, srv.Namespace, srv.Spec.Messaging.PasswordSecretRef)
+		if err != nil {
+			return corev1.Secret{}, err
+		}
+		messaging := osconf.IniFile{
+			"DEFAULT": {"transport_url": transportURL(*srv.Spec.Messaging, password)},
+		}
+		data[MessagingConfigFilename] = []byte(messaging.ToString())
+	}
+
+	secret := corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      configSecretName(srv),
+			Namespace: srv.Namespace,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+
+	if err := ctrl.SetControllerReference(&srv, &secret, r.Scheme); err != nil {
+		return secret, err
+	}
+	return secret, nil
+}
+
+// indexReferencedSecrets lists Secrets the spec takes credentials from
+func indexReferencedSecrets(rawObj runtime.Object) []string {
+	srv, ok := rawObj.(*openstackv1alpha1.KeystoneServer)
+	if !ok {
+		return nil
+	}
+	names := []string{}
+	if spec := srv.Spec.Database; spec != nil {
+		names = append(names, spec.PasswordSecretRef.Name)
+		if spec.AdminSecretRef != nil {
+			names = append(names, spec.AdminSecretRef.Name)
+		}
+	}
+	if spec := srv.Spec.Messaging; spec != nil {
+		names = append(names, spec.PasswordSecretRef.Name)
+		if spec.TLS != nil && spec.TLS.CASecretName != "" {
+			names = append(names, spec.TLS.CASecretName)
+		}
+	}
+	if spec := srv.Spec.Bootstrap; spec != nil {
+		names = append(names, spec.AdminSecretRef.Name)
+	}
+	for _, domain := range srv.Spec.Domains {
+		if domain.BindSecretRef != nil {
+			names = append(names, domain.BindSecretRef.Name)
+		}
+	}
+	return names
+}
+
+// referencedSecretRequests maps a Secret to the KeystoneServers taking credentials from it
+func (r *KeystoneServerReconciler) referencedSecretRequests(obj handler.MapObject) []reconcile.Request {
+	var servers openstackv1alpha1.KeystoneServerList
+	if err := r.List(context.Background(), &servers, client.InNamespace(obj.Meta.GetNamespace()),
+		client.MatchingField(referencedSecretKey, obj.Meta.GetName())); err != nil {
+		r.Log.Error(err, "unable to list KeystoneServers referencing secret", "secret", obj.Meta.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(servers.Items))
+	for _, srv := range servers.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: srv.Namespace, Name: srv.Name},
+		})
+	}
+	return requests
+}
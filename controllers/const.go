@@ -15,9 +15,55 @@ limitations under the License.
 
 package controllers
 
+import "time"
+
 // Configuration constants
 const (
-	KyestoneConfigFilename = "keystone.conf"
-	KyestonePolicyFilename = "policy.yaml"
-	ApacheWSGIFilename     = "wsgi-keystone.conf"
+	KyestoneConfigFilename  = "keystone.conf"
+	KyestonePolicyFilename  = "policy.yaml"
+	ApacheWSGIFilename      = "wsgi-keystone.conf"
+	DatabaseConfigFilename  = "database.conf"
+	SensitiveConfigFilename = "sensitive.conf"
+	MessagingConfigFilename = "messaging.conf"
+)
+
+// KeystoneConfDir is read by oslo.config after keystone.conf, drop-in files with credentials are mounted there
+const KeystoneConfDir = "/etc/keystone/keystone.conf.d/"
+
+// DomainConfigDir is where keystone.<domain>.conf files of domain-specific identity backends are mounted
+const DomainConfigDir = "/etc/keystonedomains"
+
+// PolicyDir is where policy.yaml is mounted when policy hot reload is enabled
+const PolicyDir = "/etc/keystone/policy/"
+
+// ConfigHashAnnotation is set on the API pod template to roll pods out on configuration changes
+const ConfigHashAnnotation = "openstack.osop.org/config-hash"
+
+// JobLabel is set on keystone Jobs to the Job name without the owner name and the hash of its
+// inputs, Jobs with the same label are superseded by a newer one once it succeeds
+const JobLabel = "openstack.osop.org/job"
+
+// Key repository rotations are recorded on their Secrets in the same update as the keys,
+// so a lost status update never causes a second rotation
+const (
+	FernetLastRotationAnnotation           = "openstack.osop.org/last-rotation-time"
+	CredentialRotationGenerationAnnotation = "openstack.osop.org/rotation-generation"
+)
+
+// KeystoneAPIPort is the port Apache serves keystone WSGI application on
+const KeystoneAPIPort = 5000
+
+// Key repository locations
+const (
+	FernetKeysPath     = "/etc/keystone/fernet-keys/"
+	CredentialKeysPath = "/etc/keystone/credential-keys/"
 )
+
+// MessagingCADir is where the broker CA bundle is mounted
+const MessagingCADir = "/etc/keystone/messaging-ca/"
+
+// MessagingCAKey is the CA bundle key in the Secret referenced by messaging TLS settings
+const MessagingCAKey = "ca.crt"
+
+// IdentityRetryInterval is how often identity resources are retried while their KeystoneServer is not ready
+const IdentityRetryInterval = 30 * time.Second
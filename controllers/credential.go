@@ -0,0 +1,105 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+func credentialKeysSecretName(srv openstackv1alpha1.KeystoneServer) string {
+	return srv.Name + "-credential-keys"
+}
+
+func credentialMigrateJobName(srv openstackv1alpha1.KeystoneServer) string {
+	return fmt.Sprintf("%s-credential-migrate-%d", srv.Name, srv.Status.CredentialKeys.RotationGeneration)
+}
+
+// rotatedCredentialGeneration is the rotation generation the keys in the Secret were rotated for
+func rotatedCredentialGeneration(srv openstackv1alpha1.KeystoneServer, secret corev1.Secret) (int64, error) {
+	value, ok := secret.Annotations[CredentialRotationGenerationAnnotation]
+	if !ok {
+		// keys rotated before the annotation was introduced
+		return srv.Status.CredentialKeys.RotationGeneration, nil
+	}
+	generation, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s annotation on secret %s: %v", CredentialRotationGenerationAnnotation, secret.Name, err)
+	}
+	return generation, nil
+}
+
+// rotateCredentialKeys rotates credential keys when spec.credential.rotationGeneration is
+// increased. Keys are only rotated once per generation, the following reconciles run
+// keystone-manage credential_migrate so existing credentials are re-encrypted with the new primary key.
+// Like keystone-manage credential_rotate, keys are not rotated again until the migration succeeded,
+// otherwise the key existing credentials are still encrypted with could be purged.
+func (r *KeystoneServerReconciler) rotateCredentialKeys(ctx context.Context, srv *openstackv1alpha1.KeystoneServer, secret *corev1.Secret) error {
+	status := &srv.Status.CredentialKeys
+	rotated, err := rotatedCredentialGeneration(*srv, *secret)
+	if err != nil {
+		return err
+	}
+	if rotated > status.RotationGeneration {
+		// the status update of the last rotation was lost
+		status.RotationGeneration = rotated
+		status.MigrationState = openstackv1alpha1.CredentialKeysMigrating
+	}
+
+	migrated := status.MigrationState == "" || status.MigrationState == openstackv1alpha1.CredentialKeysMigrated
+	if srv.Spec.Credential.RotationGeneration > rotated && migrated {
+		keys, err := rotateKeyRepository(secret.Data, CredentialMaxActiveKeysDefault)
+		if err != nil {
+			return err
+		}
+		secret.Data = keys
+		if secret.Annotations == nil {
+			secret.Annotations = make(map[string]string)
+		}
+		secret.Annotations[CredentialRotationGenerationAnnotation] = strconv.FormatInt(srv.Spec.Credential.RotationGeneration, 10)
+		if err = r.Update(ctx, secret); err != nil {
+			return err
+		}
+		status.RotationGeneration = srv.Spec.Credential.RotationGeneration
+		status.MigrationState = openstackv1alpha1.CredentialKeysMigrating
+	}
+
+	if status.MigrationState != openstackv1alpha1.CredentialKeysMigrating &&
+		status.MigrationState != openstackv1alpha1.CredentialKeysMigrationFailed {
+		return nil
+	}
+
+	job, err := r.createJob(*srv, credentialMigrateJobName(*srv), []string{"keystone-manage", "credential_migrate"})
+	if err != nil {
+		return err
+	}
+	done, err := r.runJob(ctx, job)
+	if err != nil {
+		status.MigrationState = openstackv1alpha1.CredentialKeysMigrationFailed
+		return err
+	}
+	if done {
+		status.MigrationState = openstackv1alpha1.CredentialKeysMigrated
+	} else {
+		status.MigrationState = openstackv1alpha1.CredentialKeysMigrating
+	}
+	return nil
+}
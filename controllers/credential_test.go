@@ -0,0 +1,111 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+func TestRotateCredentialKeysAfterMigration(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	srv := newKeystoneServer("tenant-a", nil, nil)
+	keys, err := newKeyRepository()
+	g.Expect(err).NotTo(HaveOccurred())
+	created := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: credentialKeysSecretName(srv), Namespace: srv.Namespace},
+		Data:       keys,
+	}
+	c := fake.NewFakeClientWithScheme(newTestScheme(t), &created)
+	r := &KeystoneServerReconciler{Client: c, APIReader: c, Scheme: newTestScheme(t)}
+	secretKey := types.NamespacedName{Namespace: srv.Namespace, Name: created.Name}
+	jobKey := func() types.NamespacedName {
+		return types.NamespacedName{Namespace: srv.Namespace, Name: credentialMigrateJobName(srv)}
+	}
+	reconcile := func() (corev1.Secret, error) {
+		var secret corev1.Secret
+		g.Expect(r.Get(ctx, secretKey, &secret)).To(Succeed())
+		err := r.rotateCredentialKeys(ctx, &srv, &secret)
+		return secret, err
+	}
+	setJobStatus := func(status batchv1.JobStatus) {
+		var job batchv1.Job
+		g.Expect(r.Get(ctx, jobKey(), &job)).To(Succeed())
+		job.Status = status
+		g.Expect(r.Update(ctx, &job)).To(Succeed())
+	}
+
+	srv.Spec.Credential.RotationGeneration = 1
+	secret, err := reconcile()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(secret.Data).To(HaveLen(3))
+	g.Expect(secret.Annotations).To(HaveKeyWithValue(CredentialRotationGenerationAnnotation, "1"))
+	g.Expect(srv.Status.CredentialKeys.MigrationState).To(Equal(openstackv1alpha1.CredentialKeysMigrating))
+	g.Expect(r.Get(ctx, jobKey(), &batchv1.Job{})).To(Succeed())
+
+	// another bump before the migration finished must not purge keys
+	srv.Spec.Credential.RotationGeneration = 2
+	rotatedKeys := secret.Data
+	setJobStatus(batchv1.JobStatus{Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}})
+	g.Expect(c.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "migrate", Namespace: srv.Namespace, Labels: map[string]string{"job-name": jobKey().Name}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Message: "credential_migrate failed"}},
+		}}},
+	})).To(Succeed())
+	secret, err = reconcile()
+	g.Expect(err).To(MatchError(ContainSubstring("credential_migrate failed")))
+	g.Expect(secret.Data).To(Equal(rotatedKeys))
+	g.Expect(srv.Status.CredentialKeys.MigrationState).To(Equal(openstackv1alpha1.CredentialKeysMigrationFailed))
+	g.Expect(apierrors.IsNotFound(r.Get(ctx, jobKey(), &batchv1.Job{}))).To(BeTrue())
+
+	// the failed migration is retried
+	secret, err = reconcile()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(secret.Data).To(Equal(rotatedKeys))
+	g.Expect(srv.Status.CredentialKeys.MigrationState).To(Equal(openstackv1alpha1.CredentialKeysMigrating))
+	setJobStatus(batchv1.JobStatus{Succeeded: 1})
+	_, err = reconcile()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(srv.Status.CredentialKeys.MigrationState).To(Equal(openstackv1alpha1.CredentialKeysMigrated))
+
+	secret, err = reconcile()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(secret.Annotations).To(HaveKeyWithValue(CredentialRotationGenerationAnnotation, "2"))
+	g.Expect(srv.Status.CredentialKeys.RotationGeneration).To(Equal(int64(2)))
+
+	// the status update of the rotation was lost
+	srv.Status.CredentialKeys = openstackv1alpha1.CredentialKeysStatus{RotationGeneration: 1, MigrationState: openstackv1alpha1.CredentialKeysMigrated}
+	rotatedKeys = secret.Data
+	secret, err = reconcile()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(secret.Data).To(Equal(rotatedKeys))
+	g.Expect(srv.Status.CredentialKeys.RotationGeneration).To(Equal(int64(2)))
+	g.Expect(srv.Status.CredentialKeys.MigrationState).To(Equal(openstackv1alpha1.CredentialKeysMigrating))
+}
@@ -0,0 +1,158 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+// databaseProvisionScript creates the keystone database and user. Every statement is
+// idempotent and re-applies the password, so the Job is safe to rerun. Values expanded
+// inside double quotes are not evaluated again by the shell.
+const databaseProvisionScript = `set -e
+password=$(printf '%s' "$DB_PASSWORD" | sed -e 's/\\/\\\\/g' -e "s/'/''/g")
+mysql --host="$DB_HOST" --port="$DB_PORT" --user="$DB_ADMIN_USER" --execute="
+CREATE DATABASE IF NOT EXISTS $DB_NAME;
+CREATE USER IF NOT EXISTS $DB_USER IDENTIFIED BY '$password';
+ALTER USER $DB_USER IDENTIFIED BY '$password';
+GRANT ALL PRIVILEGES ON $DB_NAME.* TO $DB_USER;
+FLUSH PRIVILEGES;"
+`
+
+// dbSyncJobName changes whenever image or release change so the schema is synced again
+func dbSyncJobName(srv openstackv1alpha1.KeystoneServer) string {
+	return srv.Name + "-db-sync-" + hashStrings(srv.Spec.Image, srv.Spec.Release)
+}
+
+// syncDatabase runs keystone-manage db_sync and reports whether the schema is up to date
+func (r *KeystoneServerReconciler) syncDatabase(ctx context.Context, srv *openstackv1alpha1.KeystoneServer) (bool, error) {
+	job, err := r.createJob(*srv, dbSyncJobName(*srv), []string{"keystone-manage", "db_sync"})
+	if err != nil {
+		return false, err
+	}
+	done, err := r.runJob(ctx, job)
+	if err != nil || !done {
+		return false, err
+	}
+
+	srv.Status.Database.SyncedRelease = srv.Spec.Release
+	srv.Status.Database.SyncedImage = srv.Spec.Image
+	return true, nil
+}
+
+// sqlIdentifier quotes a MySQL identifier
+func sqlIdentifier(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+// sqlAccount quotes a MySQL account allowed to connect from any host
+func sqlAccount(user string) string {
+	return "'" + strings.Replace(strings.Replace(user, `\`, `\\`, -1), "'", "''", -1) + "'@'%'"
+}
+
+// dbProvisionJobName changes whenever the database, the account or any of the credentials change
+func dbProvisionJobName(srv openstackv1alpha1.KeystoneServer, admin, password corev1.Secret) string {
+	spec := srv.Spec.Database
+	return srv.Name + "-db-provision-" + hashStrings(
+		spec.Host,
+		strconv.Itoa(int(spec.Port)),
+		spec.Name,
+		spec.User,
+		spec.ClientImage,
+		admin.Name,
+		admin.ResourceVersion,
+		password.Name,
+		password.ResourceVersion,
+	)
+}
+
+// databaseProvisionEnv passes connection parameters to databaseProvisionScript, credentials
+// are only referenced from their Secrets
+func databaseProvisionEnv(spec openstackv1alpha1.DatabaseSpec, admin corev1.Secret) []corev1.EnvVar {
+	port := spec.Port
+	if port == 0 {
+		port = DatabasePortDefault
+	}
+	env := []corev1.EnvVar{
+		{Name: "DB_HOST", Value: spec.Host},
+		{Name: "DB_PORT", Value: strconv.Itoa(int(port))},
+		{Name: "DB_NAME", Value: sqlIdentifier(valueOrDefault(spec.Name, DatabaseNameDefault))},
+		{Name: "DB_USER", Value: sqlAccount(valueOrDefault(spec.User, DatabaseUserDefault))},
+		{Name: "DB_PASSWORD", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: spec.PasswordSecretRef.DeepCopy()}},
+		{Name: "MYSQL_PWD", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: *spec.AdminSecretRef,
+			Key:                  openstackv1alpha1.AdminPasswordKey,
+		}}},
+	}
+	if _, ok := admin.Data[openstackv1alpha1.AdminUsernameKey]; ok {
+		env = append(env, corev1.EnvVar{Name: "DB_ADMIN_USER", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: *spec.AdminSecretRef,
+			Key:                  openstackv1alpha1.AdminUsernameKey,
+		}}})
+	} else {
+		env = append(env, corev1.EnvVar{Name: "DB_ADMIN_USER", Value: DatabaseAdminUserDefault})
+	}
+	return env
+}
+
+// provisionDatabase creates the keystone database and user with the admin account referenced by
+// spec.database.adminSecretRef. It reports whether the latest parameters have been applied.
+func (r *KeystoneServerReconciler) provisionDatabase(ctx context.Context, srv *openstackv1alpha1.KeystoneServer) (bool, error) {
+	spec := srv.Spec.Database
+	if spec == nil || spec.AdminSecretRef == nil {
+		return true, nil
+	}
+
+	var admin, password corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: srv.Namespace, Name: spec.AdminSecretRef.Name}, &admin); err != nil {
+		return false, err
+	}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: srv.Namespace, Name: spec.PasswordSecretRef.Name}, &password); err != nil {
+		return false, err
+	}
+
+	name := dbProvisionJobName(*srv, admin, password)
+	if srv.Status.Database.ProvisionJob == name {
+		return true, nil
+	}
+
+	job, err := r.newJob(*srv, name, corev1.PodSpec{
+		Containers: []corev1.Container{{
+			Name:                     "db-provision",
+			Image:                    valueOrDefault(spec.ClientImage, DatabaseClientImageDefault),
+			Command:                  []string{"/bin/sh", "-c", databaseProvisionScript},
+			Env:                      databaseProvisionEnv(*spec, admin),
+			TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+		}},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	done, err := r.runJob(ctx, job)
+	if err != nil || !done {
+		return false, err
+	}
+	srv.Status.Database.ProvisionJob = name
+	return true, nil
+}
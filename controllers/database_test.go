@@ -0,0 +1,94 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+// databaseFixtures returns a server provisioning its database with an admin Secret and that Secret
+func databaseFixtures() (openstackv1alpha1.KeystoneServer, corev1.Secret) {
+	srv := newKeystoneServer("tenant-a", nil, nil)
+	srv.Spec.Database = &openstackv1alpha1.DatabaseSpec{
+		Host:              "mariadb",
+		Name:              "key`stone",
+		User:              "o'keystone",
+		PasswordSecretRef: corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "db"}, Key: "password"},
+		AdminSecretRef:    &corev1.LocalObjectReference{Name: "db-admin"},
+	}
+	admin := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-admin", ResourceVersion: "1"},
+		Data:       map[string][]byte{openstackv1alpha1.AdminPasswordKey: []byte("secret")},
+	}
+	return srv, admin
+}
+
+func TestDatabaseProvisionEnv(t *testing.T) {
+	g := NewWithT(t)
+	srv, admin := databaseFixtures()
+	env := map[string]corev1.EnvVar{}
+	for _, e := range databaseProvisionEnv(*srv.Spec.Database, admin) {
+		env[e.Name] = e
+	}
+
+	g.Expect(env["DB_NAME"].Value).To(Equal("`key``stone`"))
+	g.Expect(env["DB_USER"].Value).To(Equal("'o''keystone'@'%'"))
+	g.Expect(env["DB_PORT"].Value).To(Equal("3306"))
+	g.Expect(env["DB_ADMIN_USER"].Value).To(Equal(DatabaseAdminUserDefault))
+	g.Expect(env["DB_PASSWORD"].ValueFrom.SecretKeyRef.Name).To(Equal("db"))
+	g.Expect(env["MYSQL_PWD"].ValueFrom.SecretKeyRef.Name).To(Equal("db-admin"))
+
+	admin.Data[openstackv1alpha1.AdminUsernameKey] = []byte("dbadmin")
+	for _, e := range databaseProvisionEnv(*srv.Spec.Database, admin) {
+		if e.Name == "DB_ADMIN_USER" {
+			g.Expect(e.ValueFrom.SecretKeyRef.Key).To(Equal(openstackv1alpha1.AdminUsernameKey))
+		}
+	}
+}
+
+func TestDatabaseProvisionJobName(t *testing.T) {
+	g := NewWithT(t)
+	srv, admin := databaseFixtures()
+	password := corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "db", ResourceVersion: "1"}}
+	name := dbProvisionJobName(srv, admin, password)
+	g.Expect(dbProvisionJobName(srv, admin, password)).To(Equal(name))
+
+	password.ResourceVersion = "2"
+	g.Expect(dbProvisionJobName(srv, admin, password)).NotTo(Equal(name))
+}
+
+func TestIndexReferencedSecrets(t *testing.T) {
+	g := NewWithT(t)
+	srv, _ := databaseFixtures()
+	g.Expect(indexReferencedSecrets(&srv)).To(ConsistOf("db", "db-admin"))
+
+	srv.Spec.Messaging = &openstackv1alpha1.MessagingSpec{
+		Hosts:             []string{"rabbitmq"},
+		PasswordSecretRef: corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "rabbitmq"}, Key: "password"},
+		TLS:               &openstackv1alpha1.MessagingTLSSpec{CASecretName: "rabbitmq-ca"},
+	}
+	srv.Spec.Bootstrap = &openstackv1alpha1.BootstrapSpec{AdminSecretRef: corev1.LocalObjectReference{Name: "keystone-admin"}}
+	srv.Spec.Domains = []openstackv1alpha1.DomainSpec{{Name: "corp", BindSecretRef: &corev1.LocalObjectReference{Name: "corp-bind"}}, {Name: "partners"}}
+	g.Expect(indexReferencedSecrets(&srv)).To(ConsistOf("db", "db-admin", "rabbitmq", "rabbitmq-ca", "keystone-admin", "corp-bind"))
+	g.Expect(indexReferencedSecrets(&corev1.Secret{})).To(BeEmpty())
+}
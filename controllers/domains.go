@@ -0,0 +1,91 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	osconf "github.com/dukov/osop-common/pkg/openstack/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+func domainsSecretName(srv openstackv1alpha1.KeystoneServer) string {
+	return srv.Name + "-domains"
+}
+
+// domainConfig renders keystone.<domain>.conf with bind credentials applied last
+func domainConfig(domain openstackv1alpha1.DomainSpec, bind *corev1.Secret) (osconf.IniFile, error) {
+	config := osconf.IniFile{
+		"identity": {"driver": DomainIdentityDriverDefault},
+	}
+	config.Merge(copyIniFile(domain.Config))
+	if bind == nil {
+		return config, nil
+	}
+
+	password, ok := bind.Data[openstackv1alpha1.AdminPasswordKey]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s has no %q key", bind.Namespace, bind.Name, openstackv1alpha1.AdminPasswordKey)
+	}
+	ldap := osconf.Section{"password": escapeConfigValue(string(password))}
+	if user, ok := bind.Data[openstackv1alpha1.AdminUsernameKey]; ok {
+		ldap["user"] = escapeConfigValue(string(user))
+	}
+	config.Merge(osconf.IniFile{"ldap": ldap})
+	return config, nil
+}
+
+// createDomainsSecret renders domain-specific configuration files, they may hold bind
+// passwords so they are kept in a Secret mounted at DomainConfigDir
+func (r *KeystoneServerReconciler) createDomainsSecret(ctx context.Context, srv openstackv1alpha1.KeystoneServer) (corev1.Secret, error) {
+	data := make(map[string][]byte)
+	for _, domain := range srv.Spec.Domains {
+		var bind *corev1.Secret
+		if domain.BindSecretRef != nil {
+			bind = &corev1.Secret{}
+			key := types.NamespacedName{Namespace: srv.Namespace, Name: domain.BindSecretRef.Name}
+			if err := r.Get(ctx, key, bind); err != nil {
+				return corev1.Secret{}, err
+			}
+		}
+		config, err := domainConfig(domain, bind)
+		if err != nil {
+			return corev1.Secret{}, err
+		}
+		data[openstackv1alpha1.DomainConfigFilename(domain.Name)] = []byte(config.ToString())
+	}
+
+	secret := corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      domainsSecretName(srv),
+			Namespace: srv.Namespace,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+
+	if err := ctrl.SetControllerReference(&srv, &secret, r.Scheme); err != nil {
+		return secret, err
+	}
+	return secret, nil
+}
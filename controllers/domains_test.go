@@ -0,0 +1,73 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	osconf "github.com/dukov/osop-common/pkg/openstack/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+func TestDomainConfig(t *testing.T) {
+	g := NewWithT(t)
+	domain := openstackv1alpha1.DomainSpec{
+		Name:   "corp",
+		Config: osconf.IniFile{"ldap": {"url": "ldap://ldap.example.com", "password": "inline"}},
+	}
+	config, err := domainConfig(domain, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(config["identity"]).To(HaveKeyWithValue("driver", "ldap"))
+	g.Expect(config["ldap"]).To(HaveKeyWithValue("password", "inline"))
+
+	bind := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "corp-ldap", Namespace: "tenant-a"},
+		Data:       map[string][]byte{openstackv1alpha1.AdminUsernameKey: []byte("cn=keystone,dc=corp")},
+	}
+	_, err = domainConfig(domain, bind)
+	g.Expect(err).To(HaveOccurred())
+
+	bind.Data[openstackv1alpha1.AdminPasswordKey] = []byte("pa$$")
+	config, err = domainConfig(domain, bind)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(config["ldap"]).To(HaveKeyWithValue("user", "cn=keystone,dc=corp"))
+	g.Expect(config["ldap"]).To(HaveKeyWithValue("password", "pa$$$$"))
+	g.Expect(config["ldap"]).To(HaveKeyWithValue("url", "ldap://ldap.example.com"))
+	g.Expect(openstackv1alpha1.DomainConfigFilename(domain.Name)).To(Equal("keystone.corp.conf"))
+}
+
+func TestDomainConfigMount(t *testing.T) {
+	g := NewWithT(t)
+	srv := newKeystoneServer("tenant-a", nil, nil)
+	r := &KeystoneServerReconciler{Scheme: newTestScheme(t)}
+	mountPaths := func() []string {
+		dep, err := r.createDeployment(srv)
+		g.Expect(err).NotTo(HaveOccurred())
+		paths := []string{}
+		for _, mount := range dep.Spec.Template.Spec.Containers[0].VolumeMounts {
+			paths = append(paths, mount.MountPath)
+		}
+		return paths
+	}
+	g.Expect(mountPaths()).NotTo(ContainElement(DomainConfigDir))
+	srv.Spec.Domains = []openstackv1alpha1.DomainSpec{{Name: "corp"}}
+	g.Expect(mountPaths()).To(ContainElement(DomainConfigDir))
+}
@@ -0,0 +1,218 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+// fernetKeyLength is the number of random bytes in a fernet key
+const fernetKeyLength = 32
+
+func fernetKeysSecretName(srv openstackv1alpha1.KeystoneServer) string {
+	return srv.Name + "-fernet-keys"
+}
+
+// newFernetKey generates a key in the format produced by keystone-manage fernet_setup
+func newFernetKey() ([]byte, error) {
+	raw := make([]byte, fernetKeyLength)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	key := make([]byte, base64.URLEncoding.EncodedLen(len(raw)))
+	base64.URLEncoding.Encode(key, raw)
+	return key, nil
+}
+
+// newKeyRepository generates the initial key set: 0 is the staged key and 1 is the primary one
+func newKeyRepository() (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	for i := 0; i < 2; i++ {
+		key, err := newFernetKey()
+		if err != nil {
+			return nil, err
+		}
+		keys[strconv.Itoa(i)] = key
+	}
+	return keys, nil
+}
+
+func (r *KeystoneServerReconciler) createKeyRepositorySecret(srv openstackv1alpha1.KeystoneServer, name string) (corev1.Secret, error) {
+	keys, err := newKeyRepository()
+	if err != nil {
+		return corev1.Secret{}, err
+	}
+
+	secret := corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: srv.Namespace,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: keys,
+	}
+
+	if err := ctrl.SetControllerReference(&srv, &secret, r.Scheme); err != nil {
+		return secret, err
+	}
+
+	return secret, nil
+}
+
+// ensureKeyRepository returns the key repository Secret creating it if it does not exist yet.
+// Existing keys are never regenerated since this would invalidate all issued tokens
+// or make stored credentials undecryptable.
+func (r *KeystoneServerReconciler) ensureKeyRepository(ctx context.Context, srv openstackv1alpha1.KeystoneServer, name string) (corev1.Secret, error) {
+	var secret corev1.Secret
+	key := types.NamespacedName{Namespace: srv.Namespace, Name: name}
+	err := r.Get(ctx, key, &secret)
+	if err == nil {
+		return secret, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return secret, err
+	}
+
+	secret, err = r.createKeyRepositorySecret(srv, key.Name)
+	if err != nil {
+		return secret, err
+	}
+	if err = r.Create(ctx, &secret); err != nil {
+		return secret, err
+	}
+	return secret, nil
+}
+
+// rotateKeyRepository follows keystone-manage fernet_rotate: the staged key 0 is promoted
+// to primary with the highest index, a new staged key is generated and the oldest
+// secondary keys are purged so no more than maxActiveKeys remain
+func rotateKeyRepository(keys map[string][]byte, maxActiveKeys int) (map[string][]byte, error) {
+	indexes := []int{}
+	primary := 0
+	for name := range keys {
+		idx, err := strconv.Atoi(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key index %q: %v", name, err)
+		}
+		if idx > primary {
+			primary = idx
+		}
+		if idx != 0 {
+			indexes = append(indexes, idx)
+		}
+	}
+
+	rotated := make(map[string][]byte)
+	for _, idx := range indexes {
+		rotated[strconv.Itoa(idx)] = keys[strconv.Itoa(idx)]
+	}
+	if staged, ok := keys["0"]; ok {
+		rotated[strconv.Itoa(primary+1)] = staged
+		indexes = append(indexes, primary+1)
+	}
+
+	staged, err := newFernetKey()
+	if err != nil {
+		return nil, err
+	}
+	rotated["0"] = staged
+
+	sort.Sort(sort.Reverse(sort.IntSlice(indexes)))
+	for len(indexes) > maxActiveKeys-1 {
+		purge := indexes[len(indexes)-1]
+		indexes = indexes[:len(indexes)-1]
+		delete(rotated, strconv.Itoa(purge))
+	}
+	return rotated, nil
+}
+
+// lastFernetRotation is the time keys were last rotated, the Secret creation time if they never were
+func lastFernetRotation(srv openstackv1alpha1.KeystoneServer, secret corev1.Secret) (time.Time, error) {
+	if value, ok := secret.Annotations[FernetLastRotationAnnotation]; ok {
+		last, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s annotation on secret %s: %v", FernetLastRotationAnnotation, secret.Name, err)
+		}
+		return last, nil
+	}
+	// keys rotated before the annotation was introduced
+	if srv.Status.FernetKeys.LastRotationTime != nil {
+		return srv.Status.FernetKeys.LastRotationTime.Time, nil
+	}
+	return secret.CreationTimestamp.Time, nil
+}
+
+// rotateFernetKeys rotates fernet keys if the rotation interval has passed and returns
+// the time left until the next rotation, zero means rotation is disabled
+func (r *KeystoneServerReconciler) rotateFernetKeys(ctx context.Context, srv *openstackv1alpha1.KeystoneServer, secret *corev1.Secret) (time.Duration, error) {
+	last, err := lastFernetRotation(*srv, *secret)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := secret.Annotations[FernetLastRotationAnnotation]; ok {
+		// the Secret is the source of truth, status may have missed the last rotation
+		lastTime := metav1.NewTime(last)
+		srv.Status.FernetKeys.LastRotationTime = &lastTime
+		srv.Status.FernetKeys.RotationState = openstackv1alpha1.FernetKeysRotated
+	}
+
+	if srv.Spec.Fernet.RotationInterval == nil || srv.Spec.Fernet.RotationInterval.Duration <= 0 {
+		return 0, nil
+	}
+	interval := srv.Spec.Fernet.RotationInterval.Duration
+	if wait := time.Until(last.Add(interval)); wait > 0 {
+		return wait, nil
+	}
+
+	maxActiveKeys := openstackv1alpha1.FernetMaxActiveKeysDefault
+	if srv.Spec.Fernet.MaxActiveKeys != nil {
+		maxActiveKeys = *srv.Spec.Fernet.MaxActiveKeys
+	}
+	keys, err := rotateKeyRepository(secret.Data, int(maxActiveKeys))
+	if err != nil {
+		return 0, err
+	}
+	// the Update fails on a stale resourceVersion, so keys rotated by a concurrent
+	// reconcile are never rotated again before the annotation is seen
+	now := metav1.Now()
+	secret.Data = keys
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	secret.Annotations[FernetLastRotationAnnotation] = now.UTC().Format(time.RFC3339)
+	if err = r.Update(ctx, secret); err != nil {
+		return 0, err
+	}
+
+	srv.Status.FernetKeys.LastRotationTime = &now
+	srv.Status.FernetKeys.RotationState = openstackv1alpha1.FernetKeysRotated
+	return interval, nil
+}
@@ -0,0 +1,70 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+func TestRotateFernetKeysOncePerInterval(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	srv := newKeystoneServer("tenant-a", nil, nil)
+	srv.Spec.Fernet.RotationInterval = &metav1.Duration{Duration: time.Hour}
+	keys, err := newKeyRepository()
+	g.Expect(err).NotTo(HaveOccurred())
+	created := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              fernetKeysSecretName(srv),
+			Namespace:         srv.Namespace,
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+		},
+		Data: keys,
+	}
+	r := &KeystoneServerReconciler{Client: fake.NewFakeClientWithScheme(newTestScheme(t), &created), Scheme: newTestScheme(t)}
+	key := types.NamespacedName{Namespace: srv.Namespace, Name: created.Name}
+
+	var secret corev1.Secret
+	g.Expect(r.Get(ctx, key, &secret)).To(Succeed())
+	next, err := r.rotateFernetKeys(ctx, &srv, &secret)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(next).To(Equal(time.Hour))
+	g.Expect(secret.Data).To(HaveLen(3))
+	g.Expect(secret.Annotations).To(HaveKey(FernetLastRotationAnnotation))
+	g.Expect(srv.Status.FernetKeys.RotationState).To(Equal(openstackv1alpha1.FernetKeysRotated))
+
+	// the status update of the rotation was lost
+	srv.Status.FernetKeys = openstackv1alpha1.FernetKeysStatus{}
+	g.Expect(r.Get(ctx, key, &secret)).To(Succeed())
+	next, err = r.rotateFernetKeys(ctx, &srv, &secret)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(next).To(BeNumerically(">", 59*time.Minute))
+	g.Expect(secret.Data).To(HaveLen(3))
+	g.Expect(srv.Status.FernetKeys.LastRotationTime).NotTo(BeNil())
+	g.Expect(srv.Status.FernetKeys.RotationState).To(Equal(openstackv1alpha1.FernetKeysRotated))
+}
@@ -0,0 +1,356 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// identityClient is a minimal client of the keystone v3 identity API,
+// it covers only what identity resource reconcilers need
+type identityClient struct {
+	endpoint string
+	token    string
+	http     *http.Client
+}
+
+// identityCredentials are password credentials of a project scoped token
+type identityCredentials struct {
+	Username      string
+	Password      string
+	UserDomain    string
+	Project       string
+	ProjectDomain string
+}
+
+// identityError is returned for keystone API responses with an unexpected status code
+type identityError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Message    string
+}
+
+func (e *identityError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, e.Message)
+}
+
+// identityExistsError is returned for keystone objects which exist already and are not adopted
+type identityExistsError struct {
+	Kind string
+	Name string
+	ID   string
+}
+
+func (e *identityExistsError) Error() string {
+	return fmt.Sprintf("%s %q already exists in keystone with ID %s, set spec.adopt to take it over", e.Kind, e.Name, e.ID)
+}
+
+// isIdentityNotFound reports whether keystone answered 404 Not Found
+func isIdentityNotFound(err error) bool {
+	identityErr, ok := err.(*identityError)
+	return ok && identityErr.StatusCode == http.StatusNotFound
+}
+
+// ignoreIdentityNotFound treats resources already missing in keystone as deleted
+func ignoreIdentityNotFound(err error) error {
+	if isIdentityNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+type identityDomain struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type identityProject struct {
+	ID          string `json:"id,omitempty"`
+	Name        string `json:"name"`
+	DomainID    string `json:"domain_id,omitempty"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+}
+
+type identityUser struct {
+	ID               string `json:"id,omitempty"`
+	Name             string `json:"name"`
+	DomainID         string `json:"domain_id,omitempty"`
+	DefaultProjectID string `json:"default_project_id,omitempty"`
+	Description      string `json:"description"`
+	Email            string `json:"email,omitempty"`
+	Enabled          bool   `json:"enabled"`
+	Password         string `json:"password,omitempty"`
+}
+
+type identityRole struct {
+	ID          string `json:"id,omitempty"`
+	Name        string `json:"name"`
+	DomainID    string `json:"domain_id,omitempty"`
+	Description string `json:"description"`
+}
+
+// authenticateIdentity issues a project scoped token used by all requests of the returned client
+func authenticateIdentity(ctx context.Context, httpClient *http.Client, endpoint string, creds identityCredentials) (*identityClient, error) {
+	c := &identityClient{endpoint: strings.TrimSuffix(endpoint, "/"), http: httpClient}
+
+	auth := map[string]interface{}{
+		"identity": map[string]interface{}{
+			"methods": []string{"password"},
+			"password": map[string]interface{}{
+				"user": map[string]interface{}{
+					"name":     creds.Username,
+					"password": creds.Password,
+					"domain":   map[string]string{"name": creds.UserDomain},
+				},
+			},
+		},
+		"scope": map[string]interface{}{
+			"project": map[string]interface{}{
+				"name":   creds.Project,
+				"domain": map[string]string{"name": creds.ProjectDomain},
+			},
+		},
+	}
+	resp, err := c.send(ctx, http.MethodPost, "/auth/tokens", nil, map[string]interface{}{"auth": auth})
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	c.token = resp.Header.Get("X-Subject-Token")
+	if c.token == "" {
+		return nil, fmt.Errorf("keystone at %s returned no token", c.endpoint)
+	}
+	return c, nil
+}
+
+// send performs a request and fails on non 2xx responses, the caller closes the response body
+func (c *identityClient) send(ctx context.Context, method, path string, query url.Values, in interface{}) (*http.Response, error) {
+	reqURL := c.endpoint + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, reqURL, &body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("X-Auth-Token", c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+			message = apiErr.Error.Message
+		}
+		return nil, &identityError{Method: method, URL: reqURL, StatusCode: resp.StatusCode, Message: message}
+	}
+	return resp, nil
+}
+
+// request sends in wrapped into the member key and unwraps the member key of the response into out
+func (c *identityClient) request(ctx context.Context, method, path string, query url.Values, member string, in, out interface{}) error {
+	if in != nil {
+		in = map[string]interface{}{member: in}
+	}
+	resp, err := c.send(ctx, method, path, query, in)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+
+	var wrapped map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&wrapped); err != nil {
+		return err
+	}
+	data, ok := wrapped[member]
+	if !ok {
+		return fmt.Errorf("%s %s: response has no %q member", method, path, member)
+	}
+	return json.Unmarshal(data, out)
+}
+
+// domainID resolves a domain name
+func (c *identityClient) domainID(ctx context.Context, name string) (string, error) {
+	var domains []identityDomain
+	if err := c.request(ctx, http.MethodGet, "/domains", url.Values{"name": {name}}, "domains", nil, &domains); err != nil {
+		return "", err
+	}
+	if len(domains) == 0 {
+		return "", fmt.Errorf("domain %q not found", name)
+	}
+	return domains[0].ID, nil
+}
+
+func (c *identityClient) findProject(ctx context.Context, name, domainID string) (*identityProject, error) {
+	var projects []identityProject
+	query := url.Values{"name": {name}, "domain_id": {domainID}}
+	if err := c.request(ctx, http.MethodGet, "/projects", query, "projects", nil, &projects); err != nil || len(projects) == 0 {
+		return nil, err
+	}
+	return &projects[0], nil
+}
+
+func (c *identityClient) getProject(ctx context.Context, id string) (*identityProject, error) {
+	var project identityProject
+	if err := c.request(ctx, http.MethodGet, "/projects/"+id, nil, "project", nil, &project); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (c *identityClient) createProject(ctx context.Context, project identityProject) (*identityProject, error) {
+	var created identityProject
+	if err := c.request(ctx, http.MethodPost, "/projects", nil, "project", project, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *identityClient) updateProject(ctx context.Context, id string, project identityProject) error {
+	return c.request(ctx, http.MethodPatch, "/projects/"+id, nil, "project", project, nil)
+}
+
+func (c *identityClient) deleteProject(ctx context.Context, id string) error {
+	return c.request(ctx, http.MethodDelete, "/projects/"+id, nil, "", nil, nil)
+}
+
+func (c *identityClient) findUser(ctx context.Context, name, domainID string) (*identityUser, error) {
+	var users []identityUser
+	query := url.Values{"name": {name}, "domain_id": {domainID}}
+	if err := c.request(ctx, http.MethodGet, "/users", query, "users", nil, &users); err != nil || len(users) == 0 {
+		return nil, err
+	}
+	return &users[0], nil
+}
+
+func (c *identityClient) getUser(ctx context.Context, id string) (*identityUser, error) {
+	var user identityUser
+	if err := c.request(ctx, http.MethodGet, "/users/"+id, nil, "user", nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (c *identityClient) createUser(ctx context.Context, user identityUser) (*identityUser, error) {
+	var created identityUser
+	if err := c.request(ctx, http.MethodPost, "/users", nil, "user", user, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *identityClient) updateUser(ctx context.Context, id string, user identityUser) error {
+	return c.request(ctx, http.MethodPatch, "/users/"+id, nil, "user", user, nil)
+}
+
+func (c *identityClient) deleteUser(ctx context.Context, id string) error {
+	return c.request(ctx, http.MethodDelete, "/users/"+id, nil, "", nil, nil)
+}
+
+// findRole looks up a global role if domainID is empty
+func (c *identityClient) findRole(ctx context.Context, name, domainID string) (*identityRole, error) {
+	var roles []identityRole
+	query := url.Values{"name": {name}}
+	if domainID != "" {
+		query.Set("domain_id", domainID)
+	}
+	if err := c.request(ctx, http.MethodGet, "/roles", query, "roles", nil, &roles); err != nil || len(roles) == 0 {
+		return nil, err
+	}
+	return &roles[0], nil
+}
+
+func (c *identityClient) getRole(ctx context.Context, id string) (*identityRole, error) {
+	var role identityRole
+	if err := c.request(ctx, http.MethodGet, "/roles/"+id, nil, "role", nil, &role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (c *identityClient) createRole(ctx context.Context, role identityRole) (*identityRole, error) {
+	var created identityRole
+	if err := c.request(ctx, http.MethodPost, "/roles", nil, "role", role, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *identityClient) updateRole(ctx context.Context, id string, role identityRole) error {
+	return c.request(ctx, http.MethodPatch, "/roles/"+id, nil, "role", role, nil)
+}
+
+func (c *identityClient) deleteRole(ctx context.Context, id string) error {
+	return c.request(ctx, http.MethodDelete, "/roles/"+id, nil, "", nil, nil)
+}
+
+func roleAssignmentPath(projectID, userID, roleID string) string {
+	return fmt.Sprintf("/projects/%s/users/%s/roles/%s", projectID, userID, roleID)
+}
+
+// grantRole is idempotent, keystone answers 204 for existing assignments too
+func (c *identityClient) grantRole(ctx context.Context, projectID, userID, roleID string) error {
+	return c.request(ctx, http.MethodPut, roleAssignmentPath(projectID, userID, roleID), nil, "", nil, nil)
+}
+
+// hasRole reports whether the role is assigned already
+func (c *identityClient) hasRole(ctx context.Context, projectID, userID, roleID string) (bool, error) {
+	err := c.request(ctx, http.MethodHead, roleAssignmentPath(projectID, userID, roleID), nil, "", nil, nil)
+	if isIdentityNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (c *identityClient) revokeRole(ctx context.Context, projectID, userID, roleID string) error {
+	return c.request(ctx, http.MethodDelete, roleAssignmentPath(projectID, userID, roleID), nil, "", nil, nil)
+}
@@ -0,0 +1,252 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+// identityHTTPClient is shared by identity resource reconcilers
+var identityHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// errServerNotReady is returned while the referenced KeystoneServer can not serve identity requests yet
+var errServerNotReady = errors.New("keystone server is not bootstrapped yet")
+
+// identityObject is an identity resource reconciled against the keystone API
+type identityObject interface {
+	runtime.Object
+	metav1.Object
+}
+
+// identitySyncFunc creates or updates the keystone counterpart of an identity resource,
+// persist is called right after keystone objects are created
+type identitySyncFunc func(ctx context.Context, identity *identityClient, persist identityPersistFunc) error
+
+// identityCleanupFunc deletes the keystone counterpart of an identity resource
+type identityCleanupFunc func(ctx context.Context, identity *identityClient) error
+
+// identityPersistFunc writes the status of an identity resource
+type identityPersistFunc func(ctx context.Context) error
+
+// recordCreated persists the status right after a keystone object was created, so the object
+// is known to be created by the operator even if the status update at the end of the
+// reconcile is lost. If that fails the object is removed and forgotten, otherwise the next
+// reconcile would find it without an ID in status and report it as existing already.
+func recordCreated(ctx context.Context, persist identityPersistFunc, remove func(ctx context.Context) error, forget func()) error {
+	err := persist(ctx)
+	if err == nil {
+		return nil
+	}
+	if removeErr := ignoreIdentityNotFound(remove(ctx)); removeErr != nil {
+		return fmt.Errorf("unable to record created object: %v, removing it failed: %v", err, removeErr)
+	}
+	forget()
+	return fmt.Errorf("unable to record created object: %v", err)
+}
+
+// identityEndpoint is the in-cluster identity v3 URL of the KeystoneServer Service
+func identityEndpoint(srv openstackv1alpha1.KeystoneServer) string {
+	port := srv.Spec.Service.Port
+	if port == 0 {
+		port = KeystoneAPIPort
+	}
+	return fmt.Sprintf("http://%s/v3", net.JoinHostPort(serviceDNSName(srv), strconv.Itoa(int(port))))
+}
+
+// identityClientFor authenticates as the bootstrap admin of the KeystoneServer
+func identityClientFor(ctx context.Context, c client.Client, srv openstackv1alpha1.KeystoneServer) (*identityClient, error) {
+	bootstrap := srv.Spec.Bootstrap
+	if bootstrap == nil {
+		return nil, fmt.Errorf("keystone server %s has no bootstrap admin", srv.Name)
+	}
+	if !srv.Status.IsConditionTrue(openstackv1alpha1.ConditionBootstrapped) {
+		return nil, errServerNotReady
+	}
+
+	var secret corev1.Secret
+	if err := c.Get(ctx, types.NamespacedName{Namespace: srv.Namespace, Name: bootstrap.AdminSecretRef.Name}, &secret); err != nil {
+		return nil, fmt.Errorf("unable to read bootstrap admin secret %s/%s: %v", srv.Namespace, bootstrap.AdminSecretRef.Name, err)
+	}
+	password, ok := secret.Data[openstackv1alpha1.AdminPasswordKey]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s has no %q key", srv.Namespace, secret.Name, openstackv1alpha1.AdminPasswordKey)
+	}
+	username := BootstrapAdminUsernameDefault
+	if value, ok := secret.Data[openstackv1alpha1.AdminUsernameKey]; ok {
+		username = string(value)
+	}
+
+	// keystone-manage bootstrap creates the admin user and project in the default domain
+	return authenticateIdentity(ctx, identityHTTPClient, identityEndpoint(srv), identityCredentials{
+		Username:      username,
+		Password:      string(password),
+		UserDomain:    IdentityDomainDefault,
+		Project:       valueOrDefault(bootstrap.AdminProject, BootstrapAdminProjectDefault),
+		ProjectDomain: IdentityDomainDefault,
+	})
+}
+
+func hasFinalizer(obj metav1.Object, finalizer string) bool {
+	for _, f := range obj.GetFinalizers() {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+func removeFinalizer(obj metav1.Object, finalizer string) {
+	finalizers := []string{}
+	for _, f := range obj.GetFinalizers() {
+		if f != finalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	obj.SetFinalizers(finalizers)
+}
+
+// reconcileIdentity handles the finalizer and Ready condition shared by identity resources,
+// sync makes keystone match the object and cleanup removes it from keystone on deletion
+func reconcileIdentity(ctx context.Context, c client.Client, log logr.Logger, obj identityObject, serverRef corev1.LocalObjectReference,
+	status *openstackv1alpha1.IdentityStatus, sync identitySyncFunc, cleanup identityCleanupFunc) (result ctrl.Result, err error) {
+	if !obj.GetDeletionTimestamp().IsZero() {
+		if !hasFinalizer(obj, openstackv1alpha1.IdentityFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		// objects the operator did not create are left in keystone
+		if status.Created {
+			done, err := cleanupIdentity(ctx, c, log, obj.GetNamespace(), serverRef, cleanup)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if !done {
+				return ctrl.Result{RequeueAfter: IdentityRetryInterval}, nil
+			}
+		}
+		removeFinalizer(obj, openstackv1alpha1.IdentityFinalizer)
+		return ctrl.Result{}, c.Update(ctx, obj)
+	}
+
+	if !hasFinalizer(obj, openstackv1alpha1.IdentityFinalizer) {
+		obj.SetFinalizers(append(obj.GetFinalizers(), openstackv1alpha1.IdentityFinalizer))
+		if err := c.Update(ctx, obj); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	status.ObservedGeneration = obj.GetGeneration()
+	defer func() {
+		if statusErr := c.Status().Update(ctx, obj); statusErr != nil {
+			log.Error(statusErr, "unable to update status")
+			if err == nil {
+				err = statusErr
+			}
+		}
+	}()
+
+	var srv openstackv1alpha1.KeystoneServer
+	err = c.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: serverRef.Name}, &srv)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	var identity *identityClient
+	if err == nil {
+		identity, err = identityClientFor(ctx, c, srv)
+	}
+	if err == errServerNotReady || apierrors.IsNotFound(err) {
+		log.Info("Waiting for KeystoneServer", "reason", err.Error())
+		status.SetCondition(openstackv1alpha1.ConditionReady, corev1.ConditionFalse, "ServerNotReady", err.Error())
+		return ctrl.Result{RequeueAfter: IdentityRetryInterval}, nil
+	}
+	if err != nil {
+		status.SetCondition(openstackv1alpha1.ConditionReady, corev1.ConditionFalse, "AuthenticationFailed", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	persist := func(ctx context.Context) error {
+		return c.Status().Update(ctx, obj)
+	}
+	var exists *identityExistsError
+	if err := sync(ctx, identity, persist); errors.As(err, &exists) {
+		// the spec has to change before the object is taken over
+		log.Info("Keystone object exists already", "id", exists.ID)
+		status.SetCondition(openstackv1alpha1.ConditionReady, corev1.ConditionFalse, "AlreadyExists", err.Error())
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		log.Error(err, "keystone sync failed")
+		status.SetCondition(openstackv1alpha1.ConditionReady, corev1.ConditionFalse, "SyncFailed", err.Error())
+		return ctrl.Result{}, err
+	}
+	status.SetCondition(openstackv1alpha1.ConditionReady, corev1.ConditionTrue, "Synced", "")
+	return ctrl.Result{}, nil
+}
+
+// cleanupIdentity removes the keystone counterpart of a deleted object through the referenced
+// KeystoneServer. It reports whether the finalizer can be removed, cleanup is skipped when the
+// server is gone or can never authenticate again and postponed while it is not bootstrapped.
+func cleanupIdentity(ctx context.Context, c client.Client, log logr.Logger, namespace string, serverRef corev1.LocalObjectReference,
+	cleanup identityCleanupFunc) (bool, error) {
+	var srv openstackv1alpha1.KeystoneServer
+	err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: serverRef.Name}, &srv)
+	switch {
+	case apierrors.IsNotFound(err):
+		log.Info("KeystoneServer not found, skipping keystone cleanup")
+		return true, nil
+	case err != nil:
+		return false, err
+	case !srv.DeletionTimestamp.IsZero():
+		log.Info("KeystoneServer is being deleted, skipping keystone cleanup")
+		return true, nil
+	case srv.Spec.Bootstrap == nil:
+		log.Info("KeystoneServer has no bootstrap admin, skipping keystone cleanup")
+		return true, nil
+	case !srv.Status.IsConditionTrue(openstackv1alpha1.ConditionBootstrapped):
+		log.Info("Waiting for KeystoneServer to be bootstrapped before keystone cleanup")
+		return false, nil
+	}
+
+	identity, err := identityClientFor(ctx, c, srv)
+	if err != nil {
+		return false, err
+	}
+	if err := cleanup(ctx, identity); err != nil {
+		log.Error(err, "keystone cleanup failed")
+		return false, err
+	}
+	return true, nil
+}
+
+// boolOrTrue is used for enabled flags which keystone defaults to true
+func boolOrTrue(value *bool) bool {
+	return value == nil || *value
+}
@@ -0,0 +1,125 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+func TestReconcileIdentityFinalizer(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	log := ctrl.Log.WithName("test")
+	serverRef := corev1.LocalObjectReference{Name: "ks"}
+	key := types.NamespacedName{Namespace: "tenant-a", Name: "service"}
+
+	synced, cleaned := 0, 0
+	sync := func(context.Context, *identityClient, identityPersistFunc) error { synced++; return nil }
+	cleanup := func(context.Context, *identityClient) error { cleaned++; return nil }
+	reconcile := func(c client.Client) (ctrl.Result, *openstackv1alpha1.KeystoneProject, error) {
+		var project openstackv1alpha1.KeystoneProject
+		g.Expect(c.Get(ctx, key, &project)).To(Succeed())
+		result, err := reconcileIdentity(ctx, c, log, &project, serverRef, &project.Status.IdentityStatus, sync, cleanup)
+		return result, &project, err
+	}
+	deleting := func(created bool, objs ...runtime.Object) client.Client {
+		now := metav1.Now()
+		project := &openstackv1alpha1.KeystoneProject{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              key.Name,
+				Namespace:         key.Namespace,
+				DeletionTimestamp: &now,
+				Finalizers:        []string{openstackv1alpha1.IdentityFinalizer},
+			},
+			Spec: openstackv1alpha1.KeystoneProjectSpec{ServerRef: serverRef},
+		}
+		project.Status.ID, project.Status.Created = "project-1", created
+		return fake.NewFakeClientWithScheme(newTestScheme(t), append(objs, project)...)
+	}
+	server := func(bootstrap bool, bootstrapped bool) *openstackv1alpha1.KeystoneServer {
+		srv := newKeystoneServer(key.Namespace, nil, nil)
+		srv.Name = serverRef.Name
+		if bootstrap {
+			srv.Spec.Bootstrap = &openstackv1alpha1.BootstrapSpec{AdminSecretRef: corev1.LocalObjectReference{Name: "keystone-admin"}}
+		}
+		if bootstrapped {
+			srv.Status.SetCondition(openstackv1alpha1.ConditionBootstrapped, corev1.ConditionTrue, "JobSucceeded", "")
+		}
+		return &srv
+	}
+
+	// the finalizer is added before keystone is touched
+	c := fake.NewFakeClientWithScheme(newTestScheme(t), &openstackv1alpha1.KeystoneProject{
+		ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+		Spec:       openstackv1alpha1.KeystoneProjectSpec{ServerRef: serverRef},
+	})
+	result, _, err := reconcile(c)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(IdentityRetryInterval))
+	_, project, _ := reconcile(c)
+	g.Expect(project.Finalizers).To(ConsistOf(openstackv1alpha1.IdentityFinalizer))
+	g.Expect(project.Status.GetCondition(openstackv1alpha1.ConditionReady).Reason).To(Equal("ServerNotReady"))
+	g.Expect(synced).To(BeZero())
+
+	for name, c := range map[string]client.Client{
+		"not created by the operator": deleting(false, server(true, true)),
+		"server not found":            deleting(true),
+		"server without bootstrap":    deleting(true, server(false, false)),
+	} {
+		result, project, err := reconcile(c)
+		g.Expect(err).NotTo(HaveOccurred(), name)
+		g.Expect(result).To(Equal(ctrl.Result{}), name)
+		g.Expect(c.Get(ctx, key, project)).To(Succeed())
+		g.Expect(project.Finalizers).To(BeEmpty(), name)
+	}
+
+	deletedServer := server(true, true)
+	deletedServer.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	c = deleting(true, deletedServer)
+	_, project, err = reconcile(c)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.Get(ctx, key, project)).To(Succeed())
+	g.Expect(project.Finalizers).To(BeEmpty())
+
+	// cleanup waits for the server instead of dropping keystone objects
+	c = deleting(true, server(true, false))
+	result, project, err = reconcile(c)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(IdentityRetryInterval))
+	g.Expect(c.Get(ctx, key, project)).To(Succeed())
+	g.Expect(project.Finalizers).To(ConsistOf(openstackv1alpha1.IdentityFinalizer))
+
+	c = deleting(true, server(true, true))
+	_, project, err = reconcile(c)
+	g.Expect(err).To(MatchError(ContainSubstring("unable to read bootstrap admin secret tenant-a/keystone-admin")))
+	g.Expect(c.Get(ctx, key, project)).To(Succeed())
+	g.Expect(project.Finalizers).To(ConsistOf(openstackv1alpha1.IdentityFinalizer))
+	g.Expect(cleaned).To(BeZero())
+}
@@ -0,0 +1,358 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+// fakeKeystone serves the subset of the identity v3 API used by identityClient from memory
+type fakeKeystone struct {
+	objects map[string]map[string]map[string]interface{}
+	grants  map[string]bool
+	patches []map[string]interface{}
+	nextID  int
+}
+
+func newFakeKeystone() *fakeKeystone {
+	f := &fakeKeystone{
+		objects: map[string]map[string]map[string]interface{}{"domains": {}, "projects": {}, "users": {}, "roles": {}},
+		grants:  map[string]bool{},
+	}
+	f.objects["domains"]["default"] = map[string]interface{}{"id": "default", "name": "Default"}
+	return f
+}
+
+func (f *fakeKeystone) reply(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if body != nil {
+		_ = json.NewEncoder(w).Encode(body)
+	}
+}
+
+func (f *fakeKeystone) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/v3/"), "/")
+	if r.URL.Path == "/v3/auth/tokens" {
+		var body map[string]map[string]map[string]map[string]map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["auth"]["identity"]["password"]["user"]["password"] != "secret" {
+			f.reply(w, http.StatusUnauthorized, map[string]interface{}{"error": map[string]string{"message": "invalid credentials"}})
+			return
+		}
+		w.Header().Set("X-Subject-Token", "token")
+		f.reply(w, http.StatusCreated, map[string]interface{}{"token": map[string]interface{}{}})
+		return
+	}
+	if r.Header.Get("X-Auth-Token") != "token" {
+		f.reply(w, http.StatusUnauthorized, nil)
+		return
+	}
+
+	if len(path) == 6 && path[0] == "projects" && path[2] == "users" && path[4] == "roles" {
+		key := strings.Join([]string{path[1], path[3], path[5]}, "/")
+		switch {
+		case r.Method == http.MethodPut:
+			f.grants[key] = true
+		case !f.grants[key]:
+			f.reply(w, http.StatusNotFound, nil)
+			return
+		case r.Method == http.MethodDelete:
+			delete(f.grants, key)
+		}
+		f.reply(w, http.StatusNoContent, nil)
+		return
+	}
+
+	collection, ok := f.objects[path[0]]
+	if !ok {
+		f.reply(w, http.StatusNotFound, nil)
+		return
+	}
+	member := strings.TrimSuffix(path[0], "s")
+	if len(path) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			query := r.URL.Query()
+			found := []map[string]interface{}{}
+			for _, obj := range collection {
+				domainID, _ := obj["domain_id"].(string)
+				if obj["name"] == query.Get("name") && domainID == query.Get("domain_id") {
+					found = append(found, obj)
+				}
+			}
+			f.reply(w, http.StatusOK, map[string]interface{}{path[0]: found})
+		case http.MethodPost:
+			var body map[string]map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			f.nextID++
+			obj := body[member]
+			obj["id"] = fmt.Sprintf("%s-%d", member, f.nextID)
+			delete(obj, "password")
+			collection[obj["id"].(string)] = obj
+			f.reply(w, http.StatusCreated, map[string]interface{}{member: obj})
+		}
+		return
+	}
+
+	obj, ok := collection[path[1]]
+	if !ok {
+		f.reply(w, http.StatusNotFound, nil)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		f.reply(w, http.StatusOK, map[string]interface{}{member: obj})
+	case http.MethodPatch:
+		var body map[string]map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.patches = append(f.patches, body[member])
+		for key, value := range body[member] {
+			if key != "password" {
+				obj[key] = value
+			}
+		}
+		f.reply(w, http.StatusOK, map[string]interface{}{member: obj})
+	case http.MethodDelete:
+		delete(collection, path[1])
+		f.reply(w, http.StatusNoContent, nil)
+	}
+}
+
+// persistNothing stands in for the status update of objects which are not stored in the API server
+func persistNothing(context.Context) error { return nil }
+
+// newIdentityFixture starts a fake keystone and authenticates against it, the caller closes the server
+func newIdentityFixture(t *testing.T) (*fakeKeystone, *httptest.Server, *identityClient) {
+	keystone := newFakeKeystone()
+	server := httptest.NewServer(keystone)
+
+	creds := identityCredentials{Username: "admin", Password: "secret", UserDomain: "Default", Project: "admin", ProjectDomain: "Default"}
+	identity, err := authenticateIdentity(context.Background(), server.Client(), server.URL+"/v3/", creds)
+	if err != nil {
+		server.Close()
+		t.Fatalf("authentication failed: %v", err)
+	}
+	return keystone, server, identity
+}
+
+func TestIdentityErrors(t *testing.T) {
+	g := NewWithT(t)
+	_, server, identity := newIdentityFixture(t)
+	defer server.Close()
+	ctx := context.Background()
+
+	creds := identityCredentials{Username: "admin", Password: "wrong", UserDomain: "Default", Project: "admin", ProjectDomain: "Default"}
+	_, err := authenticateIdentity(ctx, server.Client(), server.URL+"/v3", creds)
+	g.Expect(err).To(MatchError(ContainSubstring("401 invalid credentials")))
+
+	_, err = identity.domainID(ctx, "missing")
+	g.Expect(err).To(MatchError(ContainSubstring(`domain "missing" not found`)))
+	g.Expect(ignoreIdentityNotFound(identity.deleteProject(ctx, "gone"))).To(Succeed())
+}
+
+func TestSyncProject(t *testing.T) {
+	g := NewWithT(t)
+	keystone, server, identity := newIdentityFixture(t)
+	defer server.Close()
+	ctx := context.Background()
+
+	disabled := false
+	project := &openstackv1alpha1.KeystoneProject{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenant-a"},
+		Spec:       openstackv1alpha1.KeystoneProjectSpec{Description: "first"},
+	}
+	g.Expect(syncProject(ctx, identity, project, persistNothing)).To(Succeed())
+	g.Expect(project.Status.ID).NotTo(BeEmpty())
+	g.Expect(project.Status.Created).To(BeTrue())
+	created := keystone.objects["projects"][project.Status.ID]
+	g.Expect(created).To(HaveKeyWithValue("name", "tenant-a"))
+	g.Expect(created).To(HaveKeyWithValue("domain_id", "default"))
+	g.Expect(created).To(HaveKeyWithValue("enabled", true))
+
+	// an existing project is only taken over on request and never marked as created
+	adopted := project.DeepCopy()
+	adopted.Status = openstackv1alpha1.KeystoneProjectStatus{}
+	err := syncProject(ctx, identity, adopted, persistNothing)
+	g.Expect(err).To(BeAssignableToTypeOf(&identityExistsError{}))
+	g.Expect(err).To(MatchError(ContainSubstring(`project "tenant-a" already exists`)))
+	g.Expect(adopted.Status.ID).To(BeEmpty())
+
+	adopted.Spec.Adopt = true
+	g.Expect(syncProject(ctx, identity, adopted, persistNothing)).To(Succeed())
+	g.Expect(adopted.Status.ID).To(Equal(project.Status.ID))
+	g.Expect(adopted.Status.Created).To(BeFalse())
+	g.Expect(keystone.patches).To(BeEmpty())
+	g.Expect(syncProject(ctx, identity, adopted, persistNothing)).To(Succeed())
+	g.Expect(adopted.Status.Created).To(BeFalse())
+
+	project.Spec.Description = "second"
+	project.Spec.Enabled = &disabled
+	g.Expect(syncProject(ctx, identity, project, persistNothing)).To(Succeed())
+	g.Expect(keystone.objects["projects"]).To(HaveLen(1))
+	g.Expect(created).To(HaveKeyWithValue("description", "second"))
+	g.Expect(created).To(HaveKeyWithValue("enabled", false))
+	g.Expect(keystone.patches).To(ConsistOf(Not(HaveKey("domain_id"))))
+}
+
+func TestSyncRecordsCreatedObjects(t *testing.T) {
+	g := NewWithT(t)
+	keystone, server, identity := newIdentityFixture(t)
+	defer server.Close()
+	ctx := context.Background()
+
+	project := &openstackv1alpha1.KeystoneProject{ObjectMeta: metav1.ObjectMeta{Name: "tenant-a", Namespace: "tenant-a"}}
+	c := fake.NewFakeClientWithScheme(newTestScheme(t), project.DeepCopy())
+	g.Expect(c.Get(ctx, types.NamespacedName{Namespace: project.Namespace, Name: project.Name}, project)).To(Succeed())
+	persist := func(ctx context.Context) error { return c.Status().Update(ctx, project) }
+	g.Expect(syncProject(ctx, identity, project, persist)).To(Succeed())
+
+	// the status update at the end of the reconcile is lost
+	var stored openstackv1alpha1.KeystoneProject
+	g.Expect(c.Get(ctx, types.NamespacedName{Namespace: project.Namespace, Name: project.Name}, &stored)).To(Succeed())
+	g.Expect(stored.Status.ID).To(Equal(project.Status.ID))
+	g.Expect(stored.Status.Created).To(BeTrue())
+	g.Expect(syncProject(ctx, identity, &stored, persistNothing)).To(Succeed())
+	g.Expect(stored.Status.Created).To(BeTrue())
+	g.Expect(keystone.objects["projects"]).To(HaveLen(1))
+
+	// objects whose creation can not be recorded are removed again
+	failing := func(context.Context) error { return errors.New("conflict") }
+	other := &openstackv1alpha1.KeystoneProject{ObjectMeta: metav1.ObjectMeta{Name: "tenant-b"}}
+	g.Expect(syncProject(ctx, identity, other, failing)).To(MatchError(ContainSubstring("unable to record created object: conflict")))
+	g.Expect(other.Status.ID).To(BeEmpty())
+	g.Expect(keystone.objects["projects"]).To(HaveLen(1))
+
+	g.Expect(syncUser(ctx, identity, &openstackv1alpha1.KeystoneUser{ObjectMeta: metav1.ObjectMeta{Name: "alice"}}, nil, persistNothing)).To(Succeed())
+	g.Expect(syncRole(ctx, identity, &openstackv1alpha1.KeystoneRole{ObjectMeta: metav1.ObjectMeta{Name: "member"}}, persistNothing)).To(Succeed())
+	assignment := &openstackv1alpha1.KeystoneRoleAssignment{
+		Spec: openstackv1alpha1.KeystoneRoleAssignmentSpec{Role: "member", User: "alice", Project: "tenant-a"},
+	}
+	g.Expect(syncRoleAssignment(ctx, identity, assignment, failing)).To(HaveOccurred())
+	g.Expect(assignment.Status.Created).To(BeFalse())
+	g.Expect(assignment.Status.RoleID).To(BeEmpty())
+	g.Expect(keystone.grants).To(BeEmpty())
+}
+
+func TestSyncUserPassword(t *testing.T) {
+	g := NewWithT(t)
+	keystone, server, identity := newIdentityFixture(t)
+	defer server.Close()
+	ctx := context.Background()
+
+	user := &openstackv1alpha1.KeystoneUser{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "tenant-a"},
+		Spec: openstackv1alpha1.KeystoneUserSpec{
+			Email:             "alice@example.com",
+			PasswordSecretRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "alice"}, Key: "password"},
+		},
+	}
+	password := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "tenant-a", ResourceVersion: "1"},
+		Data:       map[string][]byte{"password": []byte("s3cret")},
+	}
+	g.Expect(syncUser(ctx, identity, user, password, persistNothing)).To(Succeed())
+	g.Expect(user.Status.PasswordResourceVersion).To(Equal("1"))
+	g.Expect(keystone.objects["users"][user.Status.ID]).To(HaveKeyWithValue("email", "alice@example.com"))
+
+	g.Expect(syncUser(ctx, identity, user, password, persistNothing)).To(Succeed())
+	g.Expect(keystone.patches).To(BeEmpty())
+
+	password.ResourceVersion = "2"
+	password.Data["password"] = []byte("n3w")
+	g.Expect(syncUser(ctx, identity, user, password, persistNothing)).To(Succeed())
+	g.Expect(keystone.patches).To(ConsistOf(HaveKeyWithValue("password", "n3w")))
+	g.Expect(user.Status.PasswordResourceVersion).To(Equal("2"))
+
+	// adopting keeps the password until the Secret changes
+	adopted := user.DeepCopy()
+	adopted.Status = openstackv1alpha1.KeystoneUserStatus{}
+	adopted.Spec.Adopt = true
+	keystone.patches = nil
+	g.Expect(syncUser(ctx, identity, adopted, password, persistNothing)).To(Succeed())
+	g.Expect(adopted.Status.ID).To(Equal(user.Status.ID))
+	g.Expect(adopted.Status.Created).To(BeFalse())
+	g.Expect(adopted.Status.PasswordResourceVersion).To(Equal("2"))
+	g.Expect(keystone.patches).To(BeEmpty())
+
+	password.ResourceVersion = "3"
+	g.Expect(syncUser(ctx, identity, adopted, password, persistNothing)).To(Succeed())
+	g.Expect(keystone.patches).To(ConsistOf(HaveKeyWithValue("password", "n3w")))
+
+	user.Spec.DefaultProject = "missing"
+	g.Expect(syncUser(ctx, identity, user, password, persistNothing)).To(MatchError(ContainSubstring(`default project "missing" not found`)))
+}
+
+func TestSyncRoleAssignment(t *testing.T) {
+	g := NewWithT(t)
+	keystone, server, identity := newIdentityFixture(t)
+	defer server.Close()
+	ctx := context.Background()
+
+	for _, name := range []string{"tenant-a", "tenant-b"} {
+		g.Expect(syncProject(ctx, identity, &openstackv1alpha1.KeystoneProject{ObjectMeta: metav1.ObjectMeta{Name: name}}, persistNothing)).To(Succeed())
+	}
+	g.Expect(syncUser(ctx, identity, &openstackv1alpha1.KeystoneUser{ObjectMeta: metav1.ObjectMeta{Name: "alice"}}, nil, persistNothing)).To(Succeed())
+	role := &openstackv1alpha1.KeystoneRole{ObjectMeta: metav1.ObjectMeta{Name: "member"}}
+	g.Expect(syncRole(ctx, identity, role, persistNothing)).To(Succeed())
+	g.Expect(keystone.objects["roles"][role.Status.ID]).NotTo(HaveKey("domain_id"))
+
+	assignment := &openstackv1alpha1.KeystoneRoleAssignment{
+		Spec: openstackv1alpha1.KeystoneRoleAssignmentSpec{Role: "member", User: "alice", Project: "tenant-a"},
+	}
+	g.Expect(syncRoleAssignment(ctx, identity, assignment, persistNothing)).To(Succeed())
+	first := assignment.Status.ProjectID
+	g.Expect(keystone.grants).To(HaveLen(1))
+
+	assignment.Spec.Project = "tenant-b"
+	g.Expect(syncRoleAssignment(ctx, identity, assignment, persistNothing)).To(Succeed())
+	g.Expect(assignment.Status.ProjectID).NotTo(Equal(first))
+	g.Expect(keystone.grants).To(HaveLen(1))
+	g.Expect(keystone.grants).To(HaveKey(strings.Join([]string{assignment.Status.ProjectID, assignment.Status.UserID, role.Status.ID}, "/")))
+
+	g.Expect(assignment.Status.Created).To(BeTrue())
+	g.Expect(revokeRoleAssignment(ctx, identity, &assignment.Status)).To(Succeed())
+	g.Expect(keystone.grants).To(BeEmpty())
+	g.Expect(assignment.Status.RoleID).To(BeEmpty())
+
+	// assignments granted before are kept in keystone
+	g.Expect(syncRoleAssignment(ctx, identity, assignment, persistNothing)).To(Succeed())
+	g.Expect(assignment.Status.Created).To(BeTrue())
+	existing := &openstackv1alpha1.KeystoneRoleAssignment{Spec: assignment.Spec}
+	g.Expect(syncRoleAssignment(ctx, identity, existing, persistNothing)).To(Succeed())
+	g.Expect(existing.Status.Created).To(BeFalse())
+	g.Expect(revokeRoleAssignment(ctx, identity, &existing.Status)).To(Succeed())
+	g.Expect(keystone.grants).To(HaveKey(strings.Join([]string{assignment.Status.ProjectID, assignment.Status.UserID, role.Status.ID}, "/")))
+
+	assignment.Spec.Role = "admin"
+	g.Expect(syncRoleAssignment(ctx, identity, assignment, persistNothing)).To(MatchError(ContainSubstring(`role "admin" not found`)))
+}
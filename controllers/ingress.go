@@ -0,0 +1,96 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+const ingressClassAnnotation = "kubernetes.io/ingress.class"
+
+func (r *KeystoneServerReconciler) createIngress(srv openstackv1alpha1.KeystoneServer, svcPort int32) (networkingv1beta1.Ingress, error) {
+	spec := srv.Spec.Ingress
+	annotations := make(map[string]string)
+	for k, v := range spec.Annotations {
+		annotations[k] = v
+	}
+	if spec.Class != "" {
+		annotations[ingressClassAnnotation] = spec.Class
+	}
+
+	ing := networkingv1beta1.Ingress{
+		TypeMeta: metav1.TypeMeta{APIVersion: networkingv1beta1.SchemeGroupVersion.String(), Kind: "Ingress"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        srv.Name,
+			Namespace:   srv.Namespace,
+			Annotations: annotations,
+		},
+		Spec: networkingv1beta1.IngressSpec{
+			Rules: []networkingv1beta1.IngressRule{
+				{
+					Host: spec.Host,
+					IngressRuleValue: networkingv1beta1.IngressRuleValue{
+						HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+							Paths: []networkingv1beta1.HTTPIngressPath{
+								{
+									Path: "/",
+									Backend: networkingv1beta1.IngressBackend{
+										ServiceName: srv.Name,
+										ServicePort: intstr.FromInt(int(svcPort)),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if spec.TLSSecretName != "" {
+		ing.Spec.TLS = []networkingv1beta1.IngressTLS{
+			{
+				Hosts:      []string{spec.Host},
+				SecretName: spec.TLSSecretName,
+			},
+		}
+	}
+
+	if err := ctrl.SetControllerReference(&srv, &ing, r.Scheme); err != nil {
+		return ing, err
+	}
+	return ing, nil
+}
+
+// deleteIngress removes the owned Ingress left after spec.ingress was unset
+func (r *KeystoneServerReconciler) deleteIngress(ctx context.Context, srv openstackv1alpha1.KeystoneServer) error {
+	var ing networkingv1beta1.Ingress
+	if err := r.Get(ctx, types.NamespacedName{Namespace: srv.Namespace, Name: srv.Name}, &ing); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(&ing, &srv) {
+		return nil
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, &ing))
+}
@@ -0,0 +1,175 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+var jobBackoffLimit int32 = 6
+
+// jobRetryInterval is how long a failed Job and its pods are kept for inspection before the
+// Job is deleted so the next reconcile runs it again
+var jobRetryInterval = time.Minute
+
+// createJob builds a keystone-manage Job which sees the same configuration
+// and key repositories as the keystone API pods
+func (r *KeystoneServerReconciler) createJob(srv openstackv1alpha1.KeystoneServer, name string, command []string) (batchv1.Job, error) {
+	depl, err := r.createDeployment(srv)
+	if err != nil {
+		return batchv1.Job{}, err
+	}
+
+	podSpec := *depl.Spec.Template.Spec.DeepCopy()
+	container := podSpec.Containers[0]
+	container.Name = "keystone-manage"
+	container.Command = command
+	podSpec.Containers = []corev1.Container{container}
+	return r.newJob(srv, name, podSpec)
+}
+
+// newJob wraps the pod spec into a Job owned by the KeystoneServer
+func (r *KeystoneServerReconciler) newJob(srv openstackv1alpha1.KeystoneServer, name string, podSpec corev1.PodSpec) (batchv1.Job, error) {
+	podSpec.RestartPolicy = corev1.RestartPolicyOnFailure
+	job := batchv1.Job{
+		TypeMeta: metav1.TypeMeta{APIVersion: batchv1.SchemeGroupVersion.String(), Kind: "Job"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: srv.Namespace,
+			Labels:    map[string]string{JobLabel: jobComponent(srv, name)},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &jobBackoffLimit,
+			Template: corev1.PodTemplateSpec{
+				Spec: podSpec,
+			},
+		},
+	}
+
+	if err := ctrl.SetControllerReference(&srv, &job, r.Scheme); err != nil {
+		return job, err
+	}
+	return job, nil
+}
+
+// jobComponent strips the owner name and the trailing hash or generation from the Job name
+func jobComponent(srv openstackv1alpha1.KeystoneServer, name string) string {
+	if i := strings.LastIndex(name, "-"); i > 0 {
+		name = name[:i]
+	}
+	return strings.TrimPrefix(name, srv.Name+"-")
+}
+
+// runJob creates the Job if it does not exist yet and reports whether it has completed.
+// Jobs are immutable so callers must encode their inputs into the Job name. A failed Job is
+// deleted jobRetryInterval after its failure and an error is returned until then, so the
+// reconcile is requeued with backoff and the next one after the deletion runs the Job again.
+func (r *KeystoneServerReconciler) runJob(ctx context.Context, job batchv1.Job) (bool, error) {
+	var existing batchv1.Job
+	err := r.Get(ctx, types.NamespacedName{Namespace: job.Namespace, Name: job.Name}, &existing)
+	if apierrors.IsNotFound(err) {
+		return false, r.Create(ctx, &job)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, cond := range existing.Status.Conditions {
+		if cond.Type == batchv1.JobFailed && cond.Status == corev1.ConditionTrue {
+			err := fmt.Errorf("job %s failed: %s", existing.Name, cond.Message)
+			if message := r.jobPodsMessage(ctx, existing); message != "" {
+				err = fmt.Errorf("job %s failed: %s: %s", existing.Name, cond.Message, message)
+			}
+			if time.Since(cond.LastTransitionTime.Time) < jobRetryInterval {
+				return false, err
+			}
+			if deleteErr := r.Delete(ctx, &existing, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(deleteErr) != nil {
+				return false, deleteErr
+			}
+			return false, err
+		}
+	}
+	if existing.Status.Succeeded == 0 {
+		return false, nil
+	}
+	return true, r.deleteSupersededJobs(ctx, existing)
+}
+
+// deleteSupersededJobs deletes the Jobs the succeeded Job was created in place of,
+// their names differ from it only in the hash of their inputs
+func (r *KeystoneServerReconciler) deleteSupersededJobs(ctx context.Context, job batchv1.Job) error {
+	owner := metav1.GetControllerOf(&job)
+	component, ok := job.Labels[JobLabel]
+	if owner == nil || !ok {
+		return nil
+	}
+
+	var jobs batchv1.JobList
+	if err := r.List(ctx, &jobs, client.InNamespace(job.Namespace), client.MatchingField(ownerKey, owner.Name),
+		client.MatchingLabels{JobLabel: component}); err != nil {
+		return err
+	}
+	for i := range jobs.Items {
+		if jobs.Items[i].Name == job.Name {
+			continue
+		}
+		if err := r.Delete(ctx, &jobs.Items[i], client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jobPodsMessage returns the termination message of the most recently failed Job container,
+// pods are read from the API server so the manager does not start a Pod informer
+func (r *KeystoneServerReconciler) jobPodsMessage(ctx context.Context, job batchv1.Job) string {
+	var pods corev1.PodList
+	if err := r.APIReader.List(ctx, &pods, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name}); err != nil {
+		return ""
+	}
+
+	var last *corev1.ContainerStateTerminated
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			for _, state := range []corev1.ContainerState{status.State, status.LastTerminationState} {
+				terminated := state.Terminated
+				if terminated == nil || terminated.ExitCode == 0 || terminated.Message == "" {
+					continue
+				}
+				if last == nil || last.FinishedAt.Before(&terminated.FinishedAt) {
+					last = terminated
+				}
+			}
+		}
+	}
+	if last == nil {
+		return ""
+	}
+	return strings.TrimSpace(last.Message)
+}
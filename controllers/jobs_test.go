@@ -0,0 +1,98 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRunJob(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	srv := newKeystoneServer("tenant-a", nil, nil)
+	c := fake.NewFakeClientWithScheme(newTestScheme(t))
+	r := &KeystoneServerReconciler{Client: c, APIReader: c, Scheme: newTestScheme(t)}
+	run := func(name string) (batchv1.Job, bool, error) {
+		job, err := r.createJob(srv, name, []string{"keystone-manage", "db_sync"})
+		g.Expect(err).NotTo(HaveOccurred())
+		done, err := r.runJob(ctx, job)
+		return job, done, err
+	}
+	exists := func(name string) bool {
+		err := r.Get(ctx, types.NamespacedName{Namespace: srv.Namespace, Name: name}, &batchv1.Job{})
+		if apierrors.IsNotFound(err) {
+			return false
+		}
+		g.Expect(err).NotTo(HaveOccurred())
+		return true
+	}
+	setJobStatus := func(name string, status batchv1.JobStatus) {
+		var job batchv1.Job
+		g.Expect(r.Get(ctx, types.NamespacedName{Namespace: srv.Namespace, Name: name}, &job)).To(Succeed())
+		job.Status = status
+		g.Expect(r.Update(ctx, &job)).To(Succeed())
+	}
+	failed := func(at time.Time) batchv1.JobStatus {
+		return batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+			Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded", LastTransitionTime: metav1.NewTime(at),
+		}}}
+	}
+
+	job, done, err := run(srv.Name + "-db-sync-1")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(done).To(BeFalse())
+	g.Expect(job.Labels).To(HaveKeyWithValue(JobLabel, "db-sync"))
+
+	// a failed Job is kept for a while and then deleted so it is retried
+	setJobStatus(job.Name, failed(time.Now()))
+	_, _, err = run(job.Name)
+	g.Expect(err).To(MatchError(ContainSubstring("BackoffLimitExceeded")))
+	g.Expect(exists(job.Name)).To(BeTrue())
+	setJobStatus(job.Name, failed(time.Now().Add(-jobRetryInterval)))
+	_, _, err = run(job.Name)
+	g.Expect(err).To(MatchError(ContainSubstring("BackoffLimitExceeded")))
+	g.Expect(exists(job.Name)).To(BeFalse())
+	_, done, err = run(job.Name)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(done).To(BeFalse())
+	g.Expect(exists(job.Name)).To(BeTrue())
+
+	// Jobs superseded by new inputs are deleted once the new Job succeeded
+	_, _, err = run(srv.Name + "-db-sync-expand-1")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, _, err = run(srv.Name + "-db-sync-2")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(exists(job.Name)).To(BeTrue())
+	setJobStatus(srv.Name+"-db-sync-2", batchv1.JobStatus{Succeeded: 1})
+	_, done, err = run(srv.Name + "-db-sync-2")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(done).To(BeTrue())
+	g.Expect(exists(job.Name)).To(BeFalse())
+	g.Expect(exists(srv.Name + "-db-sync-2")).To(BeTrue())
+	g.Expect(exists(srv.Name + "-db-sync-expand-1")).To(BeTrue())
+}
@@ -0,0 +1,119 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+// KeystoneProjectReconciler reconciles a KeystoneProject object
+type KeystoneProjectReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=openstack.osop.org,resources=keystoneprojects,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=openstack.osop.org,resources=keystoneprojects/status,verbs=get;update;patch
+
+func (r *KeystoneProjectReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("keystoneproject", req.NamespacedName)
+
+	var project openstackv1alpha1.KeystoneProject
+	if err := r.Get(ctx, req.NamespacedName, &project); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	sync := func(ctx context.Context, identity *identityClient, persist identityPersistFunc) error {
+		return syncProject(ctx, identity, &project, persist)
+	}
+	cleanup := func(ctx context.Context, identity *identityClient) error {
+		if project.Status.ID == "" || !project.Status.Created {
+			return nil
+		}
+		return ignoreIdentityNotFound(identity.deleteProject(ctx, project.Status.ID))
+	}
+	return reconcileIdentity(ctx, r.Client, log, &project, project.Spec.ServerRef, &project.Status.IdentityStatus, sync, cleanup)
+}
+
+// syncProject creates the keystone project or updates it to match the spec, a project
+// which exists already is only taken over if spec.adopt is set
+func syncProject(ctx context.Context, identity *identityClient, project *openstackv1alpha1.KeystoneProject, persist identityPersistFunc) error {
+	spec := project.Spec
+	domainID, err := identity.domainID(ctx, valueOrDefault(spec.Domain, IdentityDomainDefault))
+	if err != nil {
+		return err
+	}
+	desired := identityProject{
+		Name:        valueOrDefault(spec.Name, project.Name),
+		Description: spec.Description,
+		Enabled:     boolOrTrue(spec.Enabled),
+	}
+
+	var existing *identityProject
+	if project.Status.ID != "" {
+		existing, err = identity.getProject(ctx, project.Status.ID)
+		if isIdentityNotFound(err) {
+			existing, err = nil, nil
+		}
+	} else {
+		existing, err = identity.findProject(ctx, desired.Name, domainID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		desired.DomainID = domainID
+		created, err := identity.createProject(ctx, desired)
+		if err != nil {
+			return err
+		}
+		project.Status.ID, project.Status.Created = created.ID, true
+		return recordCreated(ctx, persist,
+			func(ctx context.Context) error { return identity.deleteProject(ctx, created.ID) },
+			func() { project.Status.ID, project.Status.Created = "", false })
+	}
+	if project.Status.ID == "" {
+		if !spec.Adopt {
+			return &identityExistsError{Kind: "project", Name: desired.Name, ID: existing.ID}
+		}
+		project.Status.Created = false
+	}
+	project.Status.ID = existing.ID
+	if existing.DomainID != domainID {
+		return fmt.Errorf("project %s is in domain %s, domains can not be changed", existing.ID, existing.DomainID)
+	}
+	if existing.Name != desired.Name || existing.Description != desired.Description || existing.Enabled != desired.Enabled {
+		return identity.updateProject(ctx, existing.ID, desired)
+	}
+	return nil
+}
+
+func (r *KeystoneProjectReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&openstackv1alpha1.KeystoneProject{}).
+		Complete(r)
+}
@@ -0,0 +1,122 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+// KeystoneRoleReconciler reconciles a KeystoneRole object
+type KeystoneRoleReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=openstack.osop.org,resources=keystoneroles,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=openstack.osop.org,resources=keystoneroles/status,verbs=get;update;patch
+
+func (r *KeystoneRoleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("keystonerole", req.NamespacedName)
+
+	var role openstackv1alpha1.KeystoneRole
+	if err := r.Get(ctx, req.NamespacedName, &role); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	sync := func(ctx context.Context, identity *identityClient, persist identityPersistFunc) error {
+		return syncRole(ctx, identity, &role, persist)
+	}
+	cleanup := func(ctx context.Context, identity *identityClient) error {
+		if role.Status.ID == "" || !role.Status.Created {
+			return nil
+		}
+		return ignoreIdentityNotFound(identity.deleteRole(ctx, role.Status.ID))
+	}
+	return reconcileIdentity(ctx, r.Client, log, &role, role.Spec.ServerRef, &role.Status.IdentityStatus, sync, cleanup)
+}
+
+// syncRole creates the keystone role or updates it to match the spec, a role which
+// exists already is only taken over if spec.adopt is set
+func syncRole(ctx context.Context, identity *identityClient, role *openstackv1alpha1.KeystoneRole, persist identityPersistFunc) error {
+	spec := role.Spec
+	domainID := ""
+	if spec.Domain != "" {
+		var err error
+		if domainID, err = identity.domainID(ctx, spec.Domain); err != nil {
+			return err
+		}
+	}
+	desired := identityRole{
+		Name:        valueOrDefault(spec.Name, role.Name),
+		Description: spec.Description,
+	}
+
+	var existing *identityRole
+	var err error
+	if role.Status.ID != "" {
+		existing, err = identity.getRole(ctx, role.Status.ID)
+		if isIdentityNotFound(err) {
+			existing, err = nil, nil
+		}
+	} else {
+		existing, err = identity.findRole(ctx, desired.Name, domainID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		desired.DomainID = domainID
+		created, err := identity.createRole(ctx, desired)
+		if err != nil {
+			return err
+		}
+		role.Status.ID, role.Status.Created = created.ID, true
+		return recordCreated(ctx, persist,
+			func(ctx context.Context) error { return identity.deleteRole(ctx, created.ID) },
+			func() { role.Status.ID, role.Status.Created = "", false })
+	}
+	if role.Status.ID == "" {
+		if !spec.Adopt {
+			return &identityExistsError{Kind: "role", Name: desired.Name, ID: existing.ID}
+		}
+		role.Status.Created = false
+	}
+	role.Status.ID = existing.ID
+	if existing.DomainID != domainID {
+		return fmt.Errorf("role %s is in domain %q, domains can not be changed", existing.ID, existing.DomainID)
+	}
+	if existing.Name != desired.Name || existing.Description != desired.Description {
+		return identity.updateRole(ctx, existing.ID, desired)
+	}
+	return nil
+}
+
+func (r *KeystoneRoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&openstackv1alpha1.KeystoneRole{}).
+		Complete(r)
+}
@@ -0,0 +1,145 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+// KeystoneRoleAssignmentReconciler reconciles a KeystoneRoleAssignment object
+type KeystoneRoleAssignmentReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=openstack.osop.org,resources=keystoneroleassignments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=openstack.osop.org,resources=keystoneroleassignments/status,verbs=get;update;patch
+
+func (r *KeystoneRoleAssignmentReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("keystoneroleassignment", req.NamespacedName)
+
+	var assignment openstackv1alpha1.KeystoneRoleAssignment
+	if err := r.Get(ctx, req.NamespacedName, &assignment); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	sync := func(ctx context.Context, identity *identityClient, persist identityPersistFunc) error {
+		return syncRoleAssignment(ctx, identity, &assignment, persist)
+	}
+	cleanup := func(ctx context.Context, identity *identityClient) error {
+		return revokeRoleAssignment(ctx, identity, &assignment.Status)
+	}
+	return reconcileIdentity(ctx, r.Client, log, &assignment, assignment.Spec.ServerRef, &assignment.Status.IdentityStatus, sync, cleanup)
+}
+
+// revokeRoleAssignment revokes the assignment recorded in status if the operator granted it,
+// the status forgets the assignment either way
+func revokeRoleAssignment(ctx context.Context, identity *identityClient, status *openstackv1alpha1.KeystoneRoleAssignmentStatus) error {
+	if status.RoleID == "" || status.UserID == "" || status.ProjectID == "" {
+		return nil
+	}
+	if status.Created {
+		if err := ignoreIdentityNotFound(identity.revokeRole(ctx, status.ProjectID, status.UserID, status.RoleID)); err != nil {
+			return err
+		}
+	}
+	status.RoleID, status.UserID, status.ProjectID, status.Created = "", "", "", false
+	return nil
+}
+
+// syncRoleAssignment grants the role, an assignment previously granted for other names is revoked
+func syncRoleAssignment(ctx context.Context, identity *identityClient, assignment *openstackv1alpha1.KeystoneRoleAssignment,
+	persist identityPersistFunc) error {
+	spec := assignment.Spec
+
+	roleDomainID := ""
+	if spec.RoleDomain != "" {
+		var err error
+		if roleDomainID, err = identity.domainID(ctx, spec.RoleDomain); err != nil {
+			return err
+		}
+	}
+	role, err := identity.findRole(ctx, spec.Role, roleDomainID)
+	if err != nil {
+		return err
+	}
+	if role == nil {
+		return fmt.Errorf("role %q not found", spec.Role)
+	}
+
+	userDomainID, err := identity.domainID(ctx, valueOrDefault(spec.UserDomain, IdentityDomainDefault))
+	if err != nil {
+		return err
+	}
+	user, err := identity.findUser(ctx, spec.User, userDomainID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("user %q not found", spec.User)
+	}
+
+	projectDomainID, err := identity.domainID(ctx, valueOrDefault(spec.ProjectDomain, IdentityDomainDefault))
+	if err != nil {
+		return err
+	}
+	project, err := identity.findProject(ctx, spec.Project, projectDomainID)
+	if err != nil {
+		return err
+	}
+	if project == nil {
+		return fmt.Errorf("project %q not found", spec.Project)
+	}
+
+	status := &assignment.Status
+	changed := status.RoleID != role.ID || status.UserID != user.ID || status.ProjectID != project.ID
+	if changed {
+		if err := revokeRoleAssignment(ctx, identity, status); err != nil {
+			return err
+		}
+		granted, err := identity.hasRole(ctx, project.ID, user.ID, role.ID)
+		if err != nil {
+			return err
+		}
+		status.Created = !granted
+	}
+	if err := identity.grantRole(ctx, project.ID, user.ID, role.ID); err != nil {
+		return err
+	}
+	status.RoleID, status.UserID, status.ProjectID = role.ID, user.ID, project.ID
+	if !changed || !status.Created {
+		return nil
+	}
+	return recordCreated(ctx, persist,
+		func(ctx context.Context) error { return identity.revokeRole(ctx, project.ID, user.ID, role.ID) },
+		func() { status.RoleID, status.UserID, status.ProjectID, status.Created = "", "", "", false })
+}
+
+func (r *KeystoneRoleAssignmentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&openstackv1alpha1.KeystoneRoleAssignment{}).
+		Complete(r)
+}
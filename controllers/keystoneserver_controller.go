@@ -17,15 +17,21 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"path"
+	"strings"
 
 	"github.com/go-logr/logr"
 	k8sapps "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
 
@@ -40,16 +46,25 @@ var (
 // KeystoneServerReconciler reconciles a KeystoneServer object
 type KeystoneServerReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	// APIReader reads objects the manager cache does not watch
+	APIReader client.Reader
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
 }
 
 // +kubebuilder:rbac:groups=openstack.osop.org,resources=keystoneservers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=openstack.osop.org,resources=keystoneservers/status,verbs=get;update;patch
-// +kubebuilder:rbac:groups=openstack.osop.org,resources=deployments,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=openstack.osop.org,resources=deployments/status,verbs=get
-
-func (r *KeystoneServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps,resources=deployments/status,verbs=get
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups=core,resources=endpoints,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+
+func (r *KeystoneServerReconciler) Reconcile(req ctrl.Request) (result ctrl.Result, err error) {
 	var keystoneSrv openstackv1alpha1.KeystoneServer
 	ctx := context.Background()
 	log := r.Log.WithValues("keystoneserver", req.NamespacedName)
@@ -59,57 +74,297 @@ func (r *KeystoneServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	status := &keystoneSrv.Status
+	status.ObservedGeneration = keystoneSrv.Generation
+	defer func() {
+		updateReadyCondition(&keystoneSrv)
+		if statusErr := r.Status().Update(ctx, &keystoneSrv); statusErr != nil {
+			log.Error(statusErr, "unable to update KeystoneServer status")
+			if err == nil {
+				err = statusErr
+			}
+		}
+	}()
+
+	releaseDefaults, ok := getReleaseDefaults(keystoneSrv.Spec.Release)
+	if !ok {
+		log.Info("Unsupported release", "release", keystoneSrv.Spec.Release)
+		status.SetCondition(openstackv1alpha1.ConditionReleaseSupported, corev1.ConditionFalse, "UnknownRelease",
+			fmt.Sprintf("release %q is not supported, known releases: %s", keystoneSrv.Spec.Release, strings.Join(Releases, ", ")))
+		return ctrl.Result{}, nil
+	}
+	status.SetCondition(openstackv1alpha1.ConditionReleaseSupported, corev1.ConditionTrue, "KnownRelease", "")
+	// the defaulting webhook may be disabled, apply the same defaults in memory
+	keystoneSrv.Default()
+	if keystoneSrv.Spec.Image == "" {
+		keystoneSrv.Spec.Image = releaseDefaults.Image
+	}
+	status.Image = keystoneSrv.Spec.Image
+
 	var kDepls k8sapps.DeploymentList
 	if err := r.List(ctx, &kDepls, client.InNamespace(req.Namespace), client.MatchingField(ownerKey, req.Name)); err != nil {
 		log.Error(err, "deployments list error")
 		return ctrl.Result{}, err
 	}
 
-	cm, err := r.createConfigMap(keystoneSrv)
+	if err := r.resolveCacheServers(ctx, &keystoneSrv); err != nil {
+		log.Error(err, "unable to resolve cache servers")
+		return ctrl.Result{}, err
+	}
+	if cache := keystoneSrv.Spec.Cache; cache != nil {
+		if len(cache.Servers) > 0 {
+			status.SetCondition(openstackv1alpha1.ConditionCacheAvailable, corev1.ConditionTrue, "ServersFound", strings.Join(cache.Servers, ","))
+		} else if cache.ServiceRef != nil {
+			status.SetCondition(openstackv1alpha1.ConditionCacheAvailable, corev1.ConditionFalse, "NoReadyEndpoints",
+				fmt.Sprintf("service %s has no ready endpoints, caching is disabled", cache.ServiceRef.Name))
+		} else {
+			status.SetCondition(openstackv1alpha1.ConditionCacheAvailable, corev1.ConditionFalse, "NoServers", "no cache servers listed, caching is disabled")
+		}
+	}
+
+	cm, err := r.createConfigMap(keystoneSrv, releaseDefaults)
 	if err != nil {
+		status.SetCondition(openstackv1alpha1.ConditionConfigRendered, corev1.ConditionFalse, "RenderFailed", err.Error())
 		return ctrl.Result{}, err
 	}
 
 	log.Info("Creating ConfigMap", "ConfigMap", cm)
 	if err = r.Patch(ctx, &cm, client.Apply, applyOpts...); err != nil {
+		status.SetCondition(openstackv1alpha1.ConditionConfigRendered, corev1.ConditionFalse, "ApplyFailed", err.Error())
 		return ctrl.Result{}, err
 	}
 	log.Info("ConfigMap Created")
 
+	confSecret, err := r.createConfigSecret(ctx, keystoneSrv, releaseDefaults)
+	if err != nil {
+		status.SetCondition(openstackv1alpha1.ConditionConfigRendered, corev1.ConditionFalse, "RenderFailed", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Creating config Secret", "Secret", confSecret.Name)
+	if err = r.Patch(ctx, &confSecret, client.Apply, applyOpts...); err != nil {
+		status.SetCondition(openstackv1alpha1.ConditionConfigRendered, corev1.ConditionFalse, "ApplyFailed", err.Error())
+		return ctrl.Result{}, err
+	}
+	log.Info("Config Secret Created")
+
+	domainsSecret, err := r.createDomainsSecret(ctx, keystoneSrv)
+	if err != nil {
+		status.SetCondition(openstackv1alpha1.ConditionConfigRendered, corev1.ConditionFalse, "RenderFailed", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Creating domains Secret", "Secret", domainsSecret.Name)
+	if err = r.Patch(ctx, &domainsSecret, client.Apply, applyOpts...); err != nil {
+		status.SetCondition(openstackv1alpha1.ConditionConfigRendered, corev1.ConditionFalse, "ApplyFailed", err.Error())
+		return ctrl.Result{}, err
+	}
+	log.Info("Domains Secret Created")
+	if unknown := openstackv1alpha1.UnknownConfigOptions(keystoneSrv.Spec.Config, keystoneSrv.Spec.Release); len(unknown) > 0 {
+		status.SetCondition(openstackv1alpha1.ConditionConfigRendered, corev1.ConditionTrue, "UnknownOptions",
+			fmt.Sprintf("options unknown to release %s are allowed by the %s annotation: %s",
+				keystoneSrv.Spec.Release, openstackv1alpha1.AllowUnknownConfigAnnotation, strings.Join(unknown, ", ")))
+	} else {
+		status.SetCondition(openstackv1alpha1.ConditionConfigRendered, corev1.ConditionTrue, "Rendered", "")
+	}
+	status.OverriddenOptions = overriddenOptions(settingsConfig(keystoneSrv.Spec.Settings), keystoneSrv.Spec.Config)
+
+	fernetKeys, err := r.ensureKeyRepository(ctx, keystoneSrv, fernetKeysSecretName(keystoneSrv))
+	if err != nil {
+		log.Error(err, "unable to ensure fernet keys")
+		return ctrl.Result{}, err
+	}
+
+	nextRotation, err := r.rotateFernetKeys(ctx, &keystoneSrv, &fernetKeys)
+	if err != nil {
+		log.Error(err, "unable to rotate fernet keys")
+		return ctrl.Result{}, err
+	}
+
+	status.FernetKeys.KeyCount = int32(len(fernetKeys.Data))
+	if status.FernetKeys.RotationState == "" {
+		status.FernetKeys.RotationState = openstackv1alpha1.FernetKeysInitialized
+	}
+
+	credentialKeys, err := r.ensureKeyRepository(ctx, keystoneSrv, credentialKeysSecretName(keystoneSrv))
+	if err != nil {
+		log.Error(err, "unable to ensure credential keys")
+		return ctrl.Result{}, err
+	}
+	status.CredentialKeys.KeyCount = int32(len(credentialKeys.Data))
+
+	provisioned, err := r.provisionDatabase(ctx, &keystoneSrv)
+	if err != nil {
+		log.Error(err, "database provisioning failed")
+		status.SetCondition(openstackv1alpha1.ConditionDatabaseProvisioned, corev1.ConditionFalse, "ProvisioningFailed", err.Error())
+		return ctrl.Result{}, err
+	}
+	if keystoneSrv.Spec.Database != nil && keystoneSrv.Spec.Database.AdminSecretRef != nil {
+		if !provisioned {
+			log.Info("Waiting for database provisioning")
+			status.SetCondition(openstackv1alpha1.ConditionDatabaseProvisioned, corev1.ConditionFalse, "Provisioning", "waiting for database provisioning Job")
+			return ctrl.Result{}, nil
+		}
+		status.SetCondition(openstackv1alpha1.ConditionDatabaseProvisioned, corev1.ConditionTrue, "Provisioned", "")
+	}
+
+	upgrading := upgradeRequested(keystoneSrv)
+	var synced bool
+	if upgrading {
+		synced, err = r.expandDatabase(ctx, &keystoneSrv)
+	} else {
+		synced, err = r.syncDatabase(ctx, &keystoneSrv)
+	}
+	if err != nil {
+		log.Error(err, "database sync failed")
+		status.SetCondition(openstackv1alpha1.ConditionDatabaseSynced, corev1.ConditionFalse, "SyncFailed", err.Error())
+		return ctrl.Result{}, err
+	}
+	if !synced {
+		log.Info("Waiting for database sync")
+		status.SetCondition(openstackv1alpha1.ConditionDatabaseSynced, corev1.ConditionFalse, "Syncing", "waiting for database sync Job")
+		return ctrl.Result{}, nil
+	}
+	if !upgrading {
+		status.SetCondition(openstackv1alpha1.ConditionDatabaseSynced, corev1.ConditionTrue, "Synced",
+			fmt.Sprintf("database schema is synced to %s", status.Database.SyncedRelease))
+	}
+
 	dep, err := r.createDeployment(keystoneSrv)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
+	setConfigHash(&dep, configHash(keystoneSrv, cm, confSecret, domainsSecret))
 
 	log.Info("Creating Deployment", "Deployment", dep)
 	if err = r.Patch(ctx, &dep, client.Apply, applyOpts...); err != nil {
 		return ctrl.Result{}, err
 	}
 	log.Info("Deployment Created")
+	setDeploymentStatus(status, dep)
 
-	return ctrl.Result{}, nil
-}
+	svc, err := r.createService(keystoneSrv)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 
-func (r *KeystoneServerReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	if err := mgr.GetFieldIndexer().IndexField(&k8sapps.Deployment{}, ownerKey, func(rawObj runtime.Object) []string {
-		depl := rawObj.(*k8sapps.Deployment)
-		owner := metav1.GetControllerOf(depl)
-		if owner == nil {
-			return nil
+	log.Info("Creating Service", "Service", svc)
+	if err = r.Patch(ctx, &svc, client.Apply, applyOpts...); err != nil {
+		return ctrl.Result{}, err
+	}
+	log.Info("Service Created")
+	status.ServiceDNSName = serviceDNSName(keystoneSrv)
+
+	if keystoneSrv.Spec.Ingress != nil {
+		ing, err := r.createIngress(keystoneSrv, svc.Spec.Ports[0].Port)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		log.Info("Creating Ingress", "Ingress", ing)
+		if err = r.Patch(ctx, &ing, client.Apply, applyOpts...); err != nil {
+			return ctrl.Result{}, err
 		}
+		log.Info("Ingress Created")
+	} else if err = r.deleteIngress(ctx, keystoneSrv); err != nil {
+		return ctrl.Result{}, err
+	}
 
-		if owner.APIVersion != apiGVStr || owner.Kind != "KeystoneServer" {
-			return nil
+	if upgrading {
+		if err = r.completeUpgrade(ctx, &keystoneSrv, dep); err != nil {
+			log.Error(err, "release upgrade failed")
+			status.SetCondition(openstackv1alpha1.ConditionDatabaseSynced, corev1.ConditionFalse, "UpgradeFailed", err.Error())
+			return ctrl.Result{}, err
 		}
+		if status.Upgrade.Phase == openstackv1alpha1.UpgradePhaseCompleted {
+			status.SetCondition(openstackv1alpha1.ConditionDatabaseSynced, corev1.ConditionTrue, "Synced",
+				fmt.Sprintf("database schema is synced to %s", status.Database.SyncedRelease))
+		} else {
+			status.SetCondition(openstackv1alpha1.ConditionDatabaseSynced, corev1.ConditionFalse, "Upgrading",
+				fmt.Sprintf("upgrade to %s is in %s phase", status.Upgrade.ToRelease, status.Upgrade.Phase))
+		}
+	}
 
-		return []string{owner.Name}
-	}); err != nil {
+	bootstrapped, err := r.bootstrap(ctx, &keystoneSrv)
+	if err != nil {
+		log.Error(err, "keystone bootstrap failed")
+		status.SetCondition(openstackv1alpha1.ConditionBootstrapped, corev1.ConditionFalse, "BootstrapFailed", err.Error())
+		return ctrl.Result{}, err
+	}
+	if keystoneSrv.Spec.Bootstrap != nil {
+		if bootstrapped {
+			status.SetCondition(openstackv1alpha1.ConditionBootstrapped, corev1.ConditionTrue, "Bootstrapped", "")
+		} else {
+			status.SetCondition(openstackv1alpha1.ConditionBootstrapped, corev1.ConditionFalse, "Bootstrapping", "waiting for bootstrap Job")
+		}
+	}
+
+	if err = r.rotateCredentialKeys(ctx, &keystoneSrv, &credentialKeys); err != nil {
+		log.Error(err, "unable to rotate credential keys")
+		return ctrl.Result{}, err
+	}
+	status.CredentialKeys.KeyCount = int32(len(credentialKeys.Data))
+
+	return ctrl.Result{RequeueAfter: nextRotation}, nil
+}
+
+// ownedTypes lists every object type created by the reconciler, changes to them trigger reconcile of the owner
+var ownedTypes = []runtime.Object{
+	&k8sapps.Deployment{},
+	&corev1.ConfigMap{},
+	&corev1.Secret{},
+	&corev1.Service{},
+	&networkingv1beta1.Ingress{},
+	&batchv1.Job{},
+}
+
+func indexOwner(rawObj runtime.Object) []string {
+	obj, ok := rawObj.(metav1.Object)
+	if !ok {
+		return nil
+	}
+	owner := metav1.GetControllerOf(obj)
+	if owner == nil {
+		return nil
+	}
+
+	if owner.APIVersion != apiGVStr || owner.Kind != "KeystoneServer" {
+		return nil
+	}
+
+	return []string{owner.Name}
+}
+
+func (r *KeystoneServerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	for _, obj := range ownedTypes {
+		if err := mgr.GetFieldIndexer().IndexField(obj, ownerKey, indexOwner); err != nil {
+			return err
+		}
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(&openstackv1alpha1.KeystoneServer{}, cacheServiceKey, indexCacheService); err != nil {
 		return err
 	}
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&openstackv1alpha1.KeystoneServer{}).
-		Owns(&k8sapps.Deployment{}).
-		Complete(r)
+	if err := mgr.GetFieldIndexer().IndexField(&openstackv1alpha1.KeystoneServer{}, referencedSecretKey, indexReferencedSecrets); err != nil {
+		return err
+	}
+
+	blder := ctrl.NewControllerManagedBy(mgr).
+		For(&openstackv1alpha1.KeystoneServer{})
+	for _, obj := range ownedTypes {
+		blder = blder.Owns(obj)
+	}
+	blder = blder.Watches(&source.Kind{Type: &corev1.Endpoints{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.cacheServiceRequests)}).
+		Watches(&source.Kind{Type: &corev1.Secret{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.referencedSecretRequests)})
+	return blder.Complete(r)
+}
+
+func apiLabels() map[string]string {
+	return map[string]string{
+		"component": "api",
+	}
 }
 
 func (r *KeystoneServerReconciler) createDeployment(srv openstackv1alpha1.KeystoneServer) (k8sapps.Deployment, error) {
@@ -119,6 +374,7 @@ func (r *KeystoneServerReconciler) createDeployment(srv openstackv1alpha1.Keysto
 
 	container := commonk8s.NewContainer("keystone-api", srv.Spec.Image, []string{"apache2", "-D", "FOREGROUND"})
 	container.Obj.Env = ServerEnvVars
+	container.Obj.Resources = srv.Spec.Resources
 
 	kConf := corev1.VolumeMount{
 		Name:      "etc-keystone",
@@ -130,6 +386,13 @@ func (r *KeystoneServerReconciler) createDeployment(srv openstackv1alpha1.Keysto
 		MountPath: path.Join("/etc/keystone", KyestonePolicyFilename),
 		SubPath:   KyestonePolicyFilename,
 	}
+	if srv.Spec.PolicyHotReload {
+		// subPath mounts are never updated, mount a directory so policy changes reach running pods
+		kPolicy = corev1.VolumeMount{
+			Name:      "keystone-policy",
+			MountPath: PolicyDir,
+		}
+	}
 	apacheMount := corev1.VolumeMount{
 		Name:      "etc-keystone",
 		MountPath: path.Join("/etc/apache2/sites-enabled", ApacheWSGIFilename),
@@ -143,21 +406,103 @@ func (r *KeystoneServerReconciler) createDeployment(srv openstackv1alpha1.Keysto
 		Name:      "apache-run",
 		MountPath: "/var/run/apache2",
 	}
+	fernetM := corev1.VolumeMount{
+		Name:      "fernet-keys",
+		MountPath: FernetKeysPath,
+		ReadOnly:  true,
+	}
+	credentialM := corev1.VolumeMount{
+		Name:      "credential-keys",
+		MountPath: CredentialKeysPath,
+		ReadOnly:  true,
+	}
+	confDirM := corev1.VolumeMount{
+		Name:      "keystone-conf-d",
+		MountPath: KeystoneConfDir,
+		ReadOnly:  true,
+	}
 
 	container.AddVolume(kConf)
 	container.AddVolume(kPolicy)
 	container.AddVolume(apacheMount)
 	container.AddVolume(aLogM)
 	container.AddVolume(aRunM)
-	labels := map[string]string{
-		"component": "api",
+	container.AddVolume(fernetM)
+	container.AddVolume(credentialM)
+	container.AddVolume(confDirM)
+	messagingCA := ""
+	if srv.Spec.Messaging != nil && srv.Spec.Messaging.TLS != nil {
+		messagingCA = srv.Spec.Messaging.TLS.CASecretName
 	}
-
-	depl := commonk8s.NewDeployment(srv.Name, srv.Namespace, srv.Spec.Replicas, labels)
+	if messagingCA != "" {
+		container.AddVolume(corev1.VolumeMount{
+			Name:      "messaging-ca",
+			MountPath: MessagingCADir,
+			ReadOnly:  true,
+		})
+	}
+	if len(srv.Spec.Domains) > 0 {
+		container.AddVolume(corev1.VolumeMount{
+			Name:      "keystone-domains",
+			MountPath: DomainConfigDir,
+			ReadOnly:  true,
+		})
+	}
+	depl := commonk8s.NewDeployment(srv.Name, srv.Namespace, srv.Spec.Replicas, apiLabels())
 	depl.AddContainer(container)
 	depl.AddVolume(vol)
 	depl.AddVolume(apacheLog)
 	depl.AddVolume(apacheRun)
+	depl.Obj.Spec.Template.Spec.Volumes = append(depl.Obj.Spec.Template.Spec.Volumes,
+		corev1.Volume{
+			Name: "fernet-keys",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: fernetKeysSecretName(srv)},
+			},
+		},
+		corev1.Volume{
+			Name: "credential-keys",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: credentialKeysSecretName(srv)},
+			},
+		},
+		corev1.Volume{
+			Name: "keystone-conf-d",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: configSecretName(srv)},
+			},
+		},
+	)
+	if messagingCA != "" {
+		depl.Obj.Spec.Template.Spec.Volumes = append(depl.Obj.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: "messaging-ca",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: messagingCA,
+					Items:      []corev1.KeyToPath{{Key: MessagingCAKey, Path: MessagingCAKey}},
+				},
+			},
+		})
+	}
+	if len(srv.Spec.Domains) > 0 {
+		depl.Obj.Spec.Template.Spec.Volumes = append(depl.Obj.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: "keystone-domains",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: domainsSecretName(srv)},
+			},
+		})
+	}
+	if srv.Spec.PolicyHotReload {
+		depl.Obj.Spec.Template.Spec.Volumes = append(depl.Obj.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: "keystone-policy",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: srv.Name},
+					Items:                []corev1.KeyToPath{{Key: KyestonePolicyFilename, Path: KyestonePolicyFilename}},
+				},
+			},
+		})
+	}
 
 	if err := ctrl.SetControllerReference(&srv, depl.Obj, r.Scheme); err != nil {
 		return *depl.Obj, err
@@ -165,15 +510,12 @@ func (r *KeystoneServerReconciler) createDeployment(srv openstackv1alpha1.Keysto
 	return *depl.Obj, nil
 }
 
-func (r *KeystoneServerReconciler) createConfigMap(srv openstackv1alpha1.KeystoneServer) (corev1.ConfigMap, error) {
+func (r *KeystoneServerReconciler) createConfigMap(srv openstackv1alpha1.KeystoneServer, defaults ReleaseDefaults) (corev1.ConfigMap, error) {
+	rendered := renderConfig(srv, defaults)
 	cfg := make(map[string]string)
-	KeystoneConfigDefaults.Merge(srv.Spec.Config)
-	cfg[KyestoneConfigFilename] = KeystoneConfigDefaults.ToString()
-
-	PolicyDefaults.Merge(srv.Spec.Policy)
-	cfg[KyestonePolicyFilename] = PolicyDefaults.ToString()
-
-	cfg[ApacheWSGIFilename] = ApacheConfig
+	cfg[KyestoneConfigFilename] = rendered.Config.ToString()
+	cfg[KyestonePolicyFilename] = rendered.Policy.ToString()
+	cfg[ApacheWSGIFilename] = defaults.ApacheConfig
 
 	cm := corev1.ConfigMap{
 		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "ConfigMap"},
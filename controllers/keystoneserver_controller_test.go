@@ -0,0 +1,132 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+const (
+	timeout  = time.Second * 30
+	interval = time.Millisecond * 250
+)
+
+var _ = Describe("KeystoneServer controller", func() {
+	var (
+		ctx context.Context
+		srv *openstackv1alpha1.KeystoneServer
+	)
+
+	getObj := func(name string, obj runtime.Object) func() error {
+		return func() error {
+			return k8sClient.Get(ctx, types.NamespacedName{Namespace: srv.Namespace, Name: name}, obj)
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		srv = &openstackv1alpha1.KeystoneServer{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "keystone-",
+				Namespace:    "default",
+			},
+			Spec: openstackv1alpha1.KeystoneServerSpec{
+				Release: DefaultRelease,
+			},
+		}
+		Expect(k8sClient.Create(ctx, srv)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(k8sClient.Delete(ctx, srv)).To(Succeed())
+	})
+
+	It("publishes the release image when the image is not set", func() {
+		defaults, ok := getReleaseDefaults(DefaultRelease)
+		Expect(ok).To(BeTrue())
+		Eventually(func() (string, error) {
+			var current openstackv1alpha1.KeystoneServer
+			err := getObj(srv.Name, &current)()
+			return current.Status.Image, err
+		}, timeout, interval).Should(Equal(defaults.Image))
+	})
+
+	It("repairs a deleted ConfigMap", func() {
+		var cm corev1.ConfigMap
+		Eventually(getObj(srv.Name, &cm), timeout, interval).Should(Succeed())
+		uid := cm.UID
+
+		Expect(k8sClient.Delete(ctx, &cm)).To(Succeed())
+		Eventually(func() (types.UID, error) {
+			var repaired corev1.ConfigMap
+			err := getObj(srv.Name, &repaired)()
+			return repaired.UID, err
+		}, timeout, interval).ShouldNot(Equal(uid))
+	})
+
+	It("reverts drift of the ConfigMap data", func() {
+		var cm corev1.ConfigMap
+		Eventually(getObj(srv.Name, &cm), timeout, interval).Should(Succeed())
+		rendered := cm.Data[KyestoneConfigFilename]
+
+		cm.Data[KyestoneConfigFilename] = "[DEFAULT]\n"
+		Expect(k8sClient.Update(ctx, &cm)).To(Succeed())
+		Eventually(func() (string, error) {
+			var repaired corev1.ConfigMap
+			err := getObj(srv.Name, &repaired)()
+			return repaired.Data[KyestoneConfigFilename], err
+		}, timeout, interval).Should(Equal(rendered))
+	})
+
+	It("repairs a deleted Service once the database is synced", func() {
+		// there is no job controller in the test environment, complete db_sync manually
+		var job batchv1.Job
+		Eventually(func() error {
+			var current openstackv1alpha1.KeystoneServer
+			if err := getObj(srv.Name, &current)(); err != nil {
+				return err
+			}
+			current.Spec.Image = ReleaseDefaultsTable[DefaultRelease].Image
+			current.Spec.Release = DefaultRelease
+			return getObj(dbSyncJobName(current), &job)()
+		}, timeout, interval).Should(Succeed())
+		job.Status.Succeeded = 1
+		Expect(k8sClient.Status().Update(ctx, &job)).To(Succeed())
+
+		var svc corev1.Service
+		Eventually(getObj(srv.Name, &svc), timeout, interval).Should(Succeed())
+		uid := svc.UID
+
+		Expect(k8sClient.Delete(ctx, &svc)).To(Succeed())
+		Eventually(func() (types.UID, error) {
+			var repaired corev1.Service
+			err := getObj(srv.Name, &repaired)()
+			return repaired.UID, err
+		}, timeout, interval).ShouldNot(Equal(uid))
+	})
+})
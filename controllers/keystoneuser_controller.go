@@ -0,0 +1,197 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+var userPasswordSecretKey = ".spec.passwordSecretRef.name"
+
+// KeystoneUserReconciler reconciles a KeystoneUser object
+type KeystoneUserReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=openstack.osop.org,resources=keystoneusers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=openstack.osop.org,resources=keystoneusers/status,verbs=get;update;patch
+
+func (r *KeystoneUserReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("keystoneuser", req.NamespacedName)
+
+	var user openstackv1alpha1.KeystoneUser
+	if err := r.Get(ctx, req.NamespacedName, &user); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	sync := func(ctx context.Context, identity *identityClient, persist identityPersistFunc) error {
+		var password *corev1.Secret
+		if ref := user.Spec.PasswordSecretRef; ref != nil {
+			password = &corev1.Secret{}
+			if err := r.Get(ctx, types.NamespacedName{Namespace: user.Namespace, Name: ref.Name}, password); err != nil {
+				return err
+			}
+		}
+		return syncUser(ctx, identity, &user, password, persist)
+	}
+	cleanup := func(ctx context.Context, identity *identityClient) error {
+		if user.Status.ID == "" || !user.Status.Created {
+			return nil
+		}
+		return ignoreIdentityNotFound(identity.deleteUser(ctx, user.Status.ID))
+	}
+	return reconcileIdentity(ctx, r.Client, log, &user, user.Spec.ServerRef, &user.Status.IdentityStatus, sync, cleanup)
+}
+
+// syncUser creates the keystone user or updates it to match the spec, a user which exists
+// already is only taken over if spec.adopt is set. The password is only sent when the
+// password Secret changed since it was last set or the user was adopted.
+func syncUser(ctx context.Context, identity *identityClient, user *openstackv1alpha1.KeystoneUser, passwordSecret *corev1.Secret,
+	persist identityPersistFunc) error {
+	spec := user.Spec
+	domainID, err := identity.domainID(ctx, valueOrDefault(spec.Domain, IdentityDomainDefault))
+	if err != nil {
+		return err
+	}
+	desired := identityUser{
+		Name:        valueOrDefault(spec.Name, user.Name),
+		Description: spec.Description,
+		Email:       spec.Email,
+		Enabled:     boolOrTrue(spec.Enabled),
+	}
+	if spec.DefaultProject != "" {
+		project, err := identity.findProject(ctx, spec.DefaultProject, domainID)
+		if err != nil {
+			return err
+		}
+		if project == nil {
+			return fmt.Errorf("default project %q not found", spec.DefaultProject)
+		}
+		desired.DefaultProjectID = project.ID
+	}
+	passwordVersion := ""
+	if passwordSecret != nil {
+		value, ok := passwordSecret.Data[spec.PasswordSecretRef.Key]
+		if !ok {
+			return fmt.Errorf("secret %s/%s has no %q key", passwordSecret.Namespace, passwordSecret.Name, spec.PasswordSecretRef.Key)
+		}
+		desired.Password = string(value)
+		passwordVersion = passwordSecret.ResourceVersion
+	}
+
+	var existing *identityUser
+	if user.Status.ID != "" {
+		existing, err = identity.getUser(ctx, user.Status.ID)
+		if isIdentityNotFound(err) {
+			existing, err = nil, nil
+		}
+	} else {
+		existing, err = identity.findUser(ctx, desired.Name, domainID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		desired.DomainID = domainID
+		created, err := identity.createUser(ctx, desired)
+		if err != nil {
+			return err
+		}
+		user.Status.ID, user.Status.Created = created.ID, true
+		user.Status.PasswordResourceVersion = passwordVersion
+		return recordCreated(ctx, persist,
+			func(ctx context.Context) error { return identity.deleteUser(ctx, created.ID) },
+			func() { user.Status.ID, user.Status.Created, user.Status.PasswordResourceVersion = "", false, "" })
+	}
+	adopted := user.Status.ID == ""
+	if adopted {
+		if !spec.Adopt {
+			return &identityExistsError{Kind: "user", Name: desired.Name, ID: existing.ID}
+		}
+		user.Status.Created = false
+	}
+	user.Status.ID = existing.ID
+	if existing.DomainID != domainID {
+		return fmt.Errorf("user %s is in domain %s, domains can not be changed", existing.ID, existing.DomainID)
+	}
+	// the password of an adopted user is kept until its Secret changes
+	if adopted || passwordVersion == user.Status.PasswordResourceVersion {
+		desired.Password = ""
+	}
+	if desired.Password != "" || existing.Name != desired.Name || existing.Description != desired.Description ||
+		existing.Email != desired.Email || existing.Enabled != desired.Enabled || existing.DefaultProjectID != desired.DefaultProjectID {
+		if err := identity.updateUser(ctx, existing.ID, desired); err != nil {
+			return err
+		}
+	}
+	user.Status.PasswordResourceVersion = passwordVersion
+	return nil
+}
+
+func indexUserPasswordSecret(rawObj runtime.Object) []string {
+	user := rawObj.(*openstackv1alpha1.KeystoneUser)
+	if user.Spec.PasswordSecretRef == nil {
+		return nil
+	}
+	return []string{user.Spec.PasswordSecretRef.Name}
+}
+
+// passwordSecretRequests maps a Secret to the users taking their password from it
+func (r *KeystoneUserReconciler) passwordSecretRequests(obj handler.MapObject) []reconcile.Request {
+	var users openstackv1alpha1.KeystoneUserList
+	if err := r.List(context.Background(), &users, client.InNamespace(obj.Meta.GetNamespace()),
+		client.MatchingField(userPasswordSecretKey, obj.Meta.GetName())); err != nil {
+		r.Log.Error(err, "unable to list users of password secret", "secret", obj.Meta.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(users.Items))
+	for _, user := range users.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: user.Namespace, Name: user.Name},
+		})
+	}
+	return requests
+}
+
+func (r *KeystoneUserReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(&openstackv1alpha1.KeystoneUser{}, userPasswordSecretKey, indexUserPasswordSecret); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&openstackv1alpha1.KeystoneUser{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.passwordSecretRequests)}).
+		Complete(r)
+}
@@ -0,0 +1,67 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"net"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	osconf "github.com/dukov/osop-common/pkg/openstack/config"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+// transportURL builds the oslo.messaging URL listing every broker host with the same credentials
+func transportURL(spec openstackv1alpha1.MessagingSpec, password string) string {
+	port := MessagingPortDefault
+	if spec.TLS != nil {
+		port = MessagingTLSPortDefault
+	}
+
+	user := url.UserPassword(spec.User, password).String()
+	hosts := make([]string, 0, len(spec.Hosts))
+	for _, host := range spec.Hosts {
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+		hosts = append(hosts, user+"@"+host)
+	}
+	return escapeConfigValue("rabbit://" + strings.Join(hosts, ",") + "/" + url.PathEscape(spec.VHost))
+}
+
+// messagingConfig renders options which do not hold credentials, notifications are
+// disabled when there is no broker to send them to
+func messagingConfig(srv openstackv1alpha1.KeystoneServer) osconf.IniFile {
+	spec := srv.Spec.Messaging
+	if spec == nil {
+		if _, ok := srv.Spec.Config["DEFAULT"]["transport_url"]; ok {
+			return osconf.IniFile{}
+		}
+		return osconf.IniFile{"oslo_messaging_notifications": {"driver": "noop"}}
+	}
+	if spec.TLS == nil {
+		return osconf.IniFile{}
+	}
+
+	rabbit := osconf.Section{"ssl": "true"}
+	if spec.TLS.CASecretName != "" {
+		rabbit["ssl_ca_file"] = path.Join(MessagingCADir, MessagingCAKey)
+	}
+	return osconf.IniFile{"oslo_messaging_rabbit": rabbit}
+}
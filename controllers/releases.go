@@ -0,0 +1,75 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	osconf "github.com/dukov/osop-common/pkg/openstack/config"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+// ReleaseDefaults holds default configuration for a particular OpenStack release
+type ReleaseDefaults struct {
+	Image        string
+	Config       osconf.IniFile
+	Policy       osconf.Policy
+	ApacheConfig string
+}
+
+// DefaultRelease is used when spec.release is not set
+const DefaultRelease = openstackv1alpha1.DefaultRelease
+
+// Releases lists supported releases ordered from the oldest to the newest one
+var Releases = openstackv1alpha1.Releases
+
+// releaseConfigOverrides holds keystone.conf defaults a release sets on top of KeystoneConfigDefaults
+var releaseConfigOverrides = map[string]osconf.IniFile{
+	// since train application credentials may carry access rules, keystone checks them against
+	// a rules file the operator does not manage and rejects every rule unless permissive
+	"train":  {"access_rules_config": {"permissive": "true"}},
+	"ussuri": {"access_rules_config": {"permissive": "true"}},
+}
+
+// ReleaseDefaultsTable holds defaults keyed by release name
+var ReleaseDefaultsTable = map[string]ReleaseDefaults{
+	"stein":  newReleaseDefaults("stein"),
+	"train":  newReleaseDefaults("train"),
+	"ussuri": newReleaseDefaults("ussuri"),
+}
+
+// newReleaseDefaults builds defaults of the release from the common ones and its overrides,
+// every release owns its maps so changing one release never affects another
+func newReleaseDefaults(release string) ReleaseDefaults {
+	config := copyIniFile(KeystoneConfigDefaults)
+	config.Merge(copyIniFile(releaseConfigOverrides[release]))
+	return ReleaseDefaults{
+		Image:        openstackv1alpha1.ReleaseImages[release],
+		Config:       config,
+		Policy:       copyPolicy(PolicyDefaults),
+		ApacheConfig: ApacheConfig,
+	}
+}
+
+// normalizeRelease maps a user provided release name to a ReleaseDefaultsTable key
+func normalizeRelease(release string) string {
+	return openstackv1alpha1.NormalizeRelease(release)
+}
+
+// getReleaseDefaults returns defaults for the release and whether the release is supported
+func getReleaseDefaults(release string) (ReleaseDefaults, bool) {
+	defaults, ok := ReleaseDefaultsTable[normalizeRelease(release)]
+	return defaults, ok
+}
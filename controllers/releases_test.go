@@ -0,0 +1,56 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestReleaseDefaultsAreIndependent(t *testing.T) {
+	g := NewWithT(t)
+	stein, ok := getReleaseDefaults("stein")
+	g.Expect(ok).To(BeTrue())
+	train, ok := getReleaseDefaults("train")
+	g.Expect(ok).To(BeTrue())
+
+	train.Config["token"]["expiration"] = "3600"
+	train.Policy["identity:list_users"] = "role:admin"
+	defer func() {
+		train.Config["token"]["expiration"] = KeystoneConfigDefaults["token"]["expiration"]
+		delete(train.Policy, "identity:list_users")
+	}()
+	g.Expect(stein.Config["token"]["expiration"]).To(Equal("43200"))
+	g.Expect(stein.Policy).NotTo(HaveKey("identity:list_users"))
+	g.Expect(KeystoneConfigDefaults["token"]["expiration"]).To(Equal("43200"))
+}
+
+func TestReleaseSpecificDefaults(t *testing.T) {
+	g := NewWithT(t)
+	for release, permissive := range map[string]bool{"stein": false, "train": true, "ussuri": true} {
+		defaults, ok := getReleaseDefaults(release)
+		g.Expect(ok).To(BeTrue(), release)
+		g.Expect(defaults.Image).To(ContainSubstring(release))
+		g.Expect(defaults.Config["token"]).To(Equal(KeystoneConfigDefaults["token"]), release)
+		if permissive {
+			g.Expect(defaults.Config["access_rules_config"]).To(HaveKeyWithValue("permissive", "true"), release)
+		} else {
+			g.Expect(defaults.Config).NotTo(HaveKey("access_rules_config"), release)
+		}
+	}
+	g.Expect(KeystoneConfigDefaults).NotTo(HaveKey("access_rules_config"))
+}
@@ -0,0 +1,169 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"sort"
+	"strconv"
+
+	osconf "github.com/dukov/osop-common/pkg/openstack/config"
+	k8sapps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+func copyIniFile(in osconf.IniFile) osconf.IniFile {
+	out := make(osconf.IniFile, len(in))
+	for section, options := range in {
+		out[section] = make(osconf.Section, len(options))
+		for key, value := range options {
+			out[section][key] = value
+		}
+	}
+	return out
+}
+
+func copyPolicy(in osconf.Policy) osconf.Policy {
+	out := make(osconf.Policy, len(in))
+	for rule, value := range in {
+		out[rule] = value
+	}
+	return out
+}
+
+// renderedConfig holds configuration rendered for a single KeystoneServer
+type renderedConfig struct {
+	Config osconf.IniFile
+	Policy osconf.Policy
+	// Sensitive holds options moved out of Config to be stored in a Secret
+	Sensitive osconf.IniFile
+}
+
+// splitSensitive moves SensitiveOptions from config into a separate IniFile
+func splitSensitive(config osconf.IniFile) osconf.IniFile {
+	sensitive := osconf.IniFile{}
+	for section, options := range SensitiveOptions {
+		for _, option := range options {
+			value, ok := config[section][option]
+			if !ok {
+				continue
+			}
+			if _, ok := sensitive[section]; !ok {
+				sensitive[section] = osconf.Section{}
+			}
+			sensitive[section][option] = value
+			delete(config[section], option)
+		}
+	}
+	return sensitive
+}
+
+// removeOptions drops selected options, or whole sections when no key is given, from config
+func removeOptions(config osconf.IniFile, remove []openstackv1alpha1.ConfigKey) {
+	for _, key := range remove {
+		if key.Key == "" {
+			delete(config, key.Section)
+			continue
+		}
+		delete(config[key.Section], key.Key)
+		if len(config[key.Section]) == 0 {
+			delete(config, key.Section)
+		}
+	}
+}
+
+// renderConfig merges the server overrides on top of copies of release defaults,
+// so neither the defaults nor other servers are affected by the result
+func renderConfig(srv openstackv1alpha1.KeystoneServer, defaults ReleaseDefaults) renderedConfig {
+	config := copyIniFile(defaults.Config)
+	if srv.Spec.Database != nil {
+		// connection holds the password and is rendered into the config Secret
+		delete(config["database"], "connection")
+	}
+	// proxy headers are only trusted when requests come through the Ingress
+	config.Merge(osconf.IniFile{
+		"oslo_middleware": {"enable_proxy_headers_parsing": strconv.FormatBool(srv.Spec.Ingress != nil)},
+	})
+	config.Merge(messagingConfig(srv))
+	config.Merge(cacheConfig(srv))
+	if srv.Spec.PolicyHotReload {
+		config.Merge(osconf.IniFile{
+			"oslo_policy": {"policy_file": path.Join(PolicyDir, KyestonePolicyFilename)},
+		})
+	}
+	// raw overrides are applied last so they win over typed settings
+	config.Merge(settingsConfig(srv.Spec.Settings))
+	config.Merge(copyIniFile(srv.Spec.Config))
+	removeOptions(config, srv.Spec.ConfigRemove)
+
+	sensitive := osconf.IniFile{}
+	if srv.Spec.SensitiveConfigInSecret {
+		sensitive = splitSensitive(config)
+	}
+
+	policy := copyPolicy(defaults.Policy)
+	policy.Merge(copyPolicy(srv.Spec.Policy))
+
+	return renderedConfig{Config: config, Policy: policy, Sensitive: sensitive}
+}
+
+// configHash hashes the rendered ConfigMap and Secrets data. Policy is left out when it is hot
+// reloaded by keystone so policy-only changes do not restart pods.
+func configHash(srv openstackv1alpha1.KeystoneServer, cm corev1.ConfigMap, secrets ...corev1.Secret) string {
+	keys := make([]string, 0, len(cm.Data))
+	for key := range cm.Data {
+		if srv.Spec.PolicyHotReload && key == KyestonePolicyFilename {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, key := range keys {
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(cm.Data[key]))
+		h.Write([]byte{0})
+	}
+
+	for _, secret := range secrets {
+		keys = keys[:0]
+		for key := range secret.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			h.Write([]byte(key))
+			h.Write([]byte{0})
+			h.Write(secret.Data[key])
+			h.Write([]byte{0})
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// setConfigHash stamps the hash on the pod template so a changed hash triggers a rolling restart
+func setConfigHash(dep *k8sapps.Deployment, hash string) {
+	if dep.Spec.Template.Annotations == nil {
+		dep.Spec.Template.Annotations = make(map[string]string)
+	}
+	dep.Spec.Template.Annotations[ConfigHashAnnotation] = hash
+}
@@ -0,0 +1,70 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+func serviceDNSName(srv openstackv1alpha1.KeystoneServer) string {
+	return fmt.Sprintf("%s.%s.svc", srv.Name, srv.Namespace)
+}
+
+func (r *KeystoneServerReconciler) createService(srv openstackv1alpha1.KeystoneServer) (corev1.Service, error) {
+	spec := srv.Spec.Service
+	svcType := spec.Type
+	if svcType == "" {
+		svcType = corev1.ServiceTypeClusterIP
+	}
+	port := spec.Port
+	if port == 0 {
+		port = KeystoneAPIPort
+	}
+
+	svc := corev1.Service{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        srv.Name,
+			Namespace:   srv.Namespace,
+			Annotations: spec.Annotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     svcType,
+			Selector: apiLabels(),
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "keystone-api",
+					Protocol:   corev1.ProtocolTCP,
+					Port:       port,
+					TargetPort: intstr.FromInt(KeystoneAPIPort),
+					NodePort:   spec.NodePort,
+				},
+			},
+		},
+	}
+
+	if err := ctrl.SetControllerReference(&srv, &svc, r.Scheme); err != nil {
+		return svc, err
+	}
+	return svc, nil
+}
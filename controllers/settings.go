@@ -0,0 +1,93 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	osconf "github.com/dukov/osop-common/pkg/openstack/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+// settingsConfig renders typed settings into keystone.conf options, unset fields keep release defaults
+func settingsConfig(settings *openstackv1alpha1.KeystoneSettings) osconf.IniFile {
+	config := osconf.IniFile{}
+	if settings == nil {
+		return config
+	}
+	set := func(section, option, value string) {
+		if _, ok := config[section]; !ok {
+			config[section] = osconf.Section{}
+		}
+		config[section][option] = value
+	}
+	setInt := func(section, option string, value *int32) {
+		if value != nil {
+			set(section, option, strconv.Itoa(int(*value)))
+		}
+	}
+	setSeconds := func(section, option string, value *metav1.Duration) {
+		if value != nil {
+			set(section, option, strconv.FormatInt(int64(value.Duration/time.Second), 10))
+		}
+	}
+
+	setInt("DEFAULT", "max_token_size", settings.MaxTokenSize)
+	if token := settings.Token; token != nil {
+		setSeconds("token", "expiration", token.Expiration)
+		if token.Provider != "" {
+			set("token", "provider", token.Provider)
+		}
+	}
+	if lockout := settings.Lockout; lockout != nil {
+		setInt("security_compliance", "lockout_failure_attempts", lockout.FailureAttempts)
+		setSeconds("security_compliance", "lockout_duration", lockout.Duration)
+	}
+	if password := settings.PasswordPolicy; password != nil {
+		if password.Regex != "" {
+			set("security_compliance", "password_regex", password.Regex)
+		}
+		if password.RegexDescription != "" {
+			set("security_compliance", "password_regex_description", password.RegexDescription)
+		}
+		setInt("security_compliance", "password_expires_days", password.ExpiresDays)
+		setInt("security_compliance", "unique_last_password_count", password.UniqueLastPasswordCount)
+		setInt("security_compliance", "minimum_password_age", password.MinimumAgeDays)
+		if password.ChangeUponFirstUse != nil {
+			set("security_compliance", "change_password_upon_first_use", strconv.FormatBool(*password.ChangeUponFirstUse))
+		}
+	}
+	return config
+}
+
+// overriddenOptions lists typed settings which raw config overrides replace as "[section] option"
+func overriddenOptions(settings, config osconf.IniFile) []string {
+	var overridden []string
+	for section, options := range settings {
+		for option := range options {
+			if _, ok := config[section][option]; ok {
+				overridden = append(overridden, fmt.Sprintf("[%s] %s", section, option))
+			}
+		}
+	}
+	sort.Strings(overridden)
+	return overridden
+}
@@ -0,0 +1,53 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	osconf "github.com/dukov/osop-common/pkg/openstack/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+func TestSettingsConfig(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(settingsConfig(nil)).To(BeEmpty())
+
+	attempts := int32(3)
+	settings := &openstackv1alpha1.KeystoneSettings{
+		Token:          &openstackv1alpha1.TokenSettings{Expiration: &metav1.Duration{Duration: 2 * time.Hour}, Provider: "fernet"},
+		Lockout:        &openstackv1alpha1.LockoutSettings{FailureAttempts: &attempts},
+		PasswordPolicy: &openstackv1alpha1.PasswordPolicySettings{Regex: "^.{12,}$"},
+	}
+	g.Expect(settingsConfig(settings)).To(Equal(osconf.IniFile{
+		"token":               {"expiration": "7200", "provider": "fernet"},
+		"security_compliance": {"lockout_failure_attempts": "3", "password_regex": "^.{12,}$"},
+	}))
+}
+
+func TestOverriddenOptions(t *testing.T) {
+	g := NewWithT(t)
+	settings := osconf.IniFile{"token": {"expiration": "7200", "provider": "fernet"}}
+	g.Expect(overriddenOptions(settings, nil)).To(BeEmpty())
+	g.Expect(overriddenOptions(settings, osconf.IniFile{"custom": {"expiration": "3600"}})).To(BeEmpty())
+	g.Expect(overriddenOptions(settings, osconf.IniFile{"token": {"provider": "jws", "expiration": "3600"}})).To(Equal(
+		[]string{"[token] expiration", "[token] provider"}))
+}
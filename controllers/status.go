@@ -0,0 +1,72 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	k8sapps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+// setDeploymentStatus copies replica counts and availability of the owned Deployment
+func setDeploymentStatus(status *openstackv1alpha1.KeystoneServerStatus, dep k8sapps.Deployment) {
+	status.AvailableReplicas = dep.Status.AvailableReplicas
+	status.UpdatedReplicas = dep.Status.UpdatedReplicas
+
+	for _, cond := range dep.Status.Conditions {
+		if cond.Type == k8sapps.DeploymentAvailable {
+			status.SetCondition(openstackv1alpha1.ConditionDeploymentAvailable, cond.Status, cond.Reason, cond.Message)
+			return
+		}
+	}
+	status.SetCondition(openstackv1alpha1.ConditionDeploymentAvailable, corev1.ConditionUnknown, "Pending", "Deployment has no availability status yet")
+}
+
+// updateReadyCondition summarizes the other conditions into the Ready one
+func updateReadyCondition(srv *openstackv1alpha1.KeystoneServer) {
+	required := []string{
+		openstackv1alpha1.ConditionReleaseSupported,
+		openstackv1alpha1.ConditionConfigRendered,
+		openstackv1alpha1.ConditionDatabaseSynced,
+		openstackv1alpha1.ConditionDeploymentAvailable,
+	}
+	if srv.Spec.Database != nil && srv.Spec.Database.AdminSecretRef != nil {
+		required = append(required, openstackv1alpha1.ConditionDatabaseProvisioned)
+	}
+	if srv.Spec.Cache != nil {
+		required = append(required, openstackv1alpha1.ConditionCacheAvailable)
+	}
+	if srv.Spec.Bootstrap != nil {
+		required = append(required, openstackv1alpha1.ConditionBootstrapped)
+	}
+
+	notReady := []string{}
+	for _, condType := range required {
+		if !srv.Status.IsConditionTrue(condType) {
+			notReady = append(notReady, condType)
+		}
+	}
+	if len(notReady) > 0 {
+		srv.Status.SetCondition(openstackv1alpha1.ConditionReady, corev1.ConditionFalse, "NotReady",
+			fmt.Sprintf("conditions not met: %s", strings.Join(notReady, ", ")))
+		return
+	}
+	srv.Status.SetCondition(openstackv1alpha1.ConditionReady, corev1.ConditionTrue, "Ready", "")
+}
@@ -0,0 +1,130 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	k8sapps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+// releaseIndex returns the position of the release in Releases or -1 if it is unknown
+func releaseIndex(release string) int {
+	for i, r := range Releases {
+		if r == normalizeRelease(release) {
+			return i
+		}
+	}
+	return -1
+}
+
+// upgradeRequested reports whether the database was synced for a different release than requested
+func upgradeRequested(srv openstackv1alpha1.KeystoneServer) bool {
+	installed := srv.Status.Database.SyncedRelease
+	return installed != "" && normalizeRelease(installed) != srv.Spec.Release
+}
+
+func dbSyncPhaseJobName(srv openstackv1alpha1.KeystoneServer, phase string) string {
+	return fmt.Sprintf("%s-db-sync-%s-%s", srv.Name, phase, hashStrings(srv.Spec.Image, srv.Spec.Release))
+}
+
+func (r *KeystoneServerReconciler) runDBSyncPhase(ctx context.Context, srv openstackv1alpha1.KeystoneServer, phase string) (bool, error) {
+	job, err := r.createJob(srv, dbSyncPhaseJobName(srv, phase), []string{"keystone-manage", "db_sync", "--" + phase})
+	if err != nil {
+		return false, err
+	}
+	return r.runJob(ctx, job)
+}
+
+// deploymentRolledOut reports whether all replicas of the Deployment run the latest pod template
+func deploymentRolledOut(dep k8sapps.Deployment) bool {
+	replicas := int32(1)
+	if dep.Spec.Replicas != nil {
+		replicas = *dep.Spec.Replicas
+	}
+	return dep.Status.ObservedGeneration >= dep.Generation &&
+		dep.Status.UpdatedReplicas == replicas &&
+		dep.Status.AvailableReplicas == replicas &&
+		dep.Status.Replicas == replicas
+}
+
+// expandDatabase starts a rolling upgrade with db_sync --expand and reports
+// whether pods running the new release may be rolled out
+func (r *KeystoneServerReconciler) expandDatabase(ctx context.Context, srv *openstackv1alpha1.KeystoneServer) (bool, error) {
+	from := normalizeRelease(srv.Status.Database.SyncedRelease)
+	to := srv.Spec.Release
+	if releaseIndex(to) < releaseIndex(from) {
+		srv.Status.SetCondition(openstackv1alpha1.ConditionUpgradeAllowed, corev1.ConditionFalse, "DowngradeNotSupported",
+			fmt.Sprintf("database is synced to %s, downgrade to %s is not supported", from, to))
+		return false, nil
+	}
+	// keystone rolling upgrades only support moving to the next release
+	if next := releaseIndex(from) + 1; releaseIndex(to) > next {
+		srv.Status.SetCondition(openstackv1alpha1.ConditionUpgradeAllowed, corev1.ConditionFalse, "SkipReleaseNotSupported",
+			fmt.Sprintf("database is synced to %s, upgrade to %s must go through %s first", from, to, Releases[next]))
+		return false, nil
+	}
+	srv.Status.SetCondition(openstackv1alpha1.ConditionUpgradeAllowed, corev1.ConditionTrue, "Upgrade",
+		fmt.Sprintf("upgrading from %s to %s", from, to))
+
+	upgrade := &srv.Status.Upgrade
+	if upgrade.FromRelease != from || upgrade.ToRelease != to {
+		*upgrade = openstackv1alpha1.UpgradeStatus{FromRelease: from, ToRelease: to, Phase: openstackv1alpha1.UpgradePhaseExpand}
+	}
+	if upgrade.Phase != openstackv1alpha1.UpgradePhaseExpand {
+		return true, nil
+	}
+
+	done, err := r.runDBSyncPhase(ctx, *srv, "expand")
+	if err != nil || !done {
+		return false, err
+	}
+	upgrade.Phase = openstackv1alpha1.UpgradePhaseRollout
+	return true, nil
+}
+
+// completeUpgrade runs db_sync --migrate and --contract once every replica runs the new release
+func (r *KeystoneServerReconciler) completeUpgrade(ctx context.Context, srv *openstackv1alpha1.KeystoneServer, dep k8sapps.Deployment) error {
+	upgrade := &srv.Status.Upgrade
+	switch upgrade.Phase {
+	case openstackv1alpha1.UpgradePhaseRollout:
+		if !deploymentRolledOut(dep) {
+			return nil
+		}
+		upgrade.Phase = openstackv1alpha1.UpgradePhaseMigrate
+		fallthrough
+	case openstackv1alpha1.UpgradePhaseMigrate:
+		done, err := r.runDBSyncPhase(ctx, *srv, "migrate")
+		if err != nil || !done {
+			return err
+		}
+		upgrade.Phase = openstackv1alpha1.UpgradePhaseContract
+		fallthrough
+	case openstackv1alpha1.UpgradePhaseContract:
+		done, err := r.runDBSyncPhase(ctx, *srv, "contract")
+		if err != nil || !done {
+			return err
+		}
+		upgrade.Phase = openstackv1alpha1.UpgradePhaseCompleted
+		srv.Status.Database.SyncedRelease = srv.Spec.Release
+		srv.Status.Database.SyncedImage = srv.Spec.Image
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+)
+
+func TestExpandDatabaseAllowsOnlyNextRelease(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	r := &KeystoneServerReconciler{Client: fake.NewFakeClientWithScheme(newTestScheme(t)), Scheme: newTestScheme(t)}
+	upgradeAllowed := func(srv openstackv1alpha1.KeystoneServer) (corev1.ConditionStatus, string) {
+		cond := srv.Status.GetCondition(openstackv1alpha1.ConditionUpgradeAllowed)
+		g.Expect(cond).NotTo(BeNil())
+		return cond.Status, cond.Reason
+	}
+
+	srv := newKeystoneServer("tenant-a", nil, nil)
+	srv.Status.Database.SyncedRelease = "stein"
+	srv.Spec.Release = "ussuri"
+	srv.Default()
+	expanded, err := r.expandDatabase(ctx, &srv)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(expanded).To(BeFalse())
+	status, reason := upgradeAllowed(srv)
+	g.Expect(status).To(Equal(corev1.ConditionFalse))
+	g.Expect(reason).To(Equal("SkipReleaseNotSupported"))
+	g.Expect(srv.Status.Upgrade.Phase).To(BeEmpty())
+
+	srv.Status.Database.SyncedRelease = "train"
+	srv.Spec.Release = "stein"
+	_, err = r.expandDatabase(ctx, &srv)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, reason = upgradeAllowed(srv)
+	g.Expect(reason).To(Equal("DowngradeNotSupported"))
+
+	srv.Spec.Release = "ussuri"
+	expanded, err = r.expandDatabase(ctx, &srv)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(expanded).To(BeFalse())
+	status, _ = upgradeAllowed(srv)
+	g.Expect(status).To(Equal(corev1.ConditionTrue))
+	g.Expect(srv.Status.Upgrade.Phase).To(Equal(openstackv1alpha1.UpgradePhaseExpand))
+
+	var jobs batchv1.JobList
+	g.Expect(r.List(ctx, &jobs, client.InNamespace(srv.Namespace))).To(Succeed())
+	g.Expect(jobs.Items).To(HaveLen(1))
+}
@@ -13,3 +13,18 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 package controllers
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// hashStrings returns a short stable hash of the values, suitable for object names
+func hashStrings(values ...string) string {
+	h := fnv.New32a()
+	for _, v := range values {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%08x", h.Sum32())
+}
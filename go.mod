@@ -5,6 +5,7 @@ go 1.13
 require (
 	github.com/dukov/osop-common v0.0.0
 	github.com/go-logr/logr v0.1.0
+	github.com/google/gofuzz v1.0.0
 	github.com/onsi/ginkgo v1.10.1
 	github.com/onsi/gomega v1.7.0
 	k8s.io/api v0.17.1
@@ -20,6 +20,7 @@ import (
 	"os"
 
 	openstackv1alpha1 "github.com/dukov/osop-keystone/api/v1alpha1"
+	openstackv1beta1 "github.com/dukov/osop-keystone/api/v1beta1"
 	"github.com/dukov/osop-keystone/controllers"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -38,6 +39,7 @@ func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 
 	_ = openstackv1alpha1.AddToScheme(scheme)
+	_ = openstackv1beta1.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -65,13 +67,56 @@ func main() {
 	}
 
 	if err = (&controllers.KeystoneServerReconciler{
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+		Log:       ctrl.Log.WithName("controllers").WithName("KeystoneServer"),
+		Scheme:    mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "KeystoneServer")
+		os.Exit(1)
+	}
+	if err = (&controllers.KeystoneProjectReconciler{
 		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("KeystoneServer"),
+		Log:    ctrl.Log.WithName("controllers").WithName("KeystoneProject"),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "KeystoneServer")
+		setupLog.Error(err, "unable to create controller", "controller", "KeystoneProject")
 		os.Exit(1)
 	}
+	if err = (&controllers.KeystoneUserReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("KeystoneUser"),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "KeystoneUser")
+		os.Exit(1)
+	}
+	if err = (&controllers.KeystoneRoleReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("KeystoneRole"),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "KeystoneRole")
+		os.Exit(1)
+	}
+	if err = (&controllers.KeystoneRoleAssignmentReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("KeystoneRoleAssignment"),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "KeystoneRoleAssignment")
+		os.Exit(1)
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&openstackv1alpha1.KeystoneServer{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "KeystoneServer")
+			os.Exit(1)
+		}
+		if err = (&openstackv1beta1.KeystoneServer{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "KeystoneServer")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")